@@ -5,9 +5,8 @@ import (
 	"os"
 
 	"github.com/Tabintel/multi-tenant-chat/backend/handlers"
-	"github.com/Tabintel/multi-tenant-chat/backend/middleware"
 	"github.com/Tabintel/multi-tenant-chat/backend/db"
-	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
 	_ "github.com/Tabintel/multi-tenant-chat/backend/docs"
 
 	"github.com/gin-gonic/gin"
@@ -28,8 +27,14 @@ func main() {
 		log.Println("No .env file found, relying on environment variables")
 	}
 
+	// "migrate up|down|status" manages the schema instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	// Connect to PostgreSQL or use mock mode
 	db.Connect()
+	srv := handlers.NewServer(repository.NewGorm(db.DB))
 
 	// Configure CORS to allow Authorization header
 	config := cors.DefaultConfig()
@@ -41,31 +46,7 @@ func main() {
 	// Swagger docs endpoint
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Auth endpoints
-	r.POST("/auth/login", handlers.Login)
-	r.POST("/auth/register", handlers.Register)
-
-	// Stream Chat token endpoint (protected)
-	r.GET("/stream/token", middleware.JWTAuth(), handlers.StreamToken)
-
-	// Tenant endpoints
-	r.POST("/tenants", middleware.JWTAuth(), middleware.RequireRole(string(models.RoleAdmin)), handlers.CreateTenant)
-	// Make GET /tenants public for login/signup dropdown
-	r.GET("/tenants", handlers.ListTenants)
-
-	// User endpoints (Admin/Moderator for create/update, Admin for delete, all roles for list)
-	r.POST("/users", middleware.JWTAuth(), middleware.RequireRole(string(models.RoleAdmin), string(models.RoleModerator)), handlers.CreateUser)
-	r.GET("/users", middleware.JWTAuth(), handlers.ListUsers)
-	r.PUT("/users/:id", middleware.JWTAuth(), middleware.RequireRole(string(models.RoleAdmin), string(models.RoleModerator)), handlers.UpdateUser)
-	r.DELETE("/users/:id", middleware.JWTAuth(), middleware.RequireRole(string(models.RoleAdmin)), handlers.DeleteUser)
-
-	// Channel endpoints (Admin/Moderator for create, all roles for list)
-	r.POST("/channels", middleware.JWTAuth(), middleware.RequireRole(string(models.RoleAdmin), string(models.RoleModerator)), handlers.CreateChannel)
-	r.GET("/channels", middleware.JWTAuth(), handlers.ListChannels)
-
-	// Messages endpoint (all authenticated users)
-	r.POST("/messages", middleware.JWTAuth(), handlers.SendMessage)
-	r.GET("/messages/:stream_id", middleware.JWTAuth(), handlers.GetMessages)
+	srv.Routes(r)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -74,4 +55,4 @@ func main() {
 	log.Printf("Starting server on :%s", port)
 	r.Run(":" + port)
 }
-// Reminder: Set DATABASE_URL=mock in .env to activate mock mode. All endpoints and Swagger docs will be available, but no real DB operations will occur.
+// Reminder: Set DATABASE_URL=mock in .env to activate mock mode. All endpoints and Swagger docs work against an in-memory database seeded with demo data and the local chat backend.
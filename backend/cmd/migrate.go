@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up [n]    apply all (or the next n) pending migrations
+  down [n]  revert the last n applied migrations (default 1)
+  status    list migrations and when they were applied`
+
+// runMigrate implements the "migrate" subcommand against DATABASE_URL and
+// returns the process exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	n := 0
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			fmt.Fprintln(os.Stderr, "n must be a positive number")
+			return 2
+		}
+	}
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" || dsn == db.MockDSN {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL must point to a PostgreSQL database (the mock database is set up automatically)")
+		return 1
+	}
+	conn, err := db.Open(dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	migrations, err := db.Migrations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+	migrator := db.NewMigrator(conn, migrations)
+
+	var done []db.Migration
+	verb := "Applied"
+	switch args[0] {
+	case "up":
+		done, err = migrator.Up(n)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		verb = "Reverted"
+		done, err = migrator.Down(n)
+	case "status":
+		return printMigrationStatus(migrator)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	for _, m := range done {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Println("Nothing to do")
+	}
+	return 0
+}
+
+func printMigrationStatus(migrator *db.Migrator) int {
+	status, err := migrator.Status()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			if s.Up == "" {
+				applied += " (missing from this binary)"
+			}
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	w.Flush()
+	return 0
+}
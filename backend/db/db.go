@@ -10,27 +10,66 @@ import (
 	"gorm.io/gorm"
 )
 
+// MockDSN is the DATABASE_URL value that switches the server to mock mode
+const MockDSN = "mock"
+
 var DB *gorm.DB
 
+// Mock reports whether the server runs against the embedded demo database
+var Mock bool
+
 func Connect() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
 		log.Fatal("DATABASE_URL environment variable is required")
 	}
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if dsn == MockDSN {
+		connectMock()
+		return
+	}
+	db, err := Open(dsn)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	DB = db
-	// Conditionally run AutoMigrate if MIGRATE_DB=true in env (for development only)
+	migrations, err := Migrations()
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator := NewMigrator(db, migrations)
+	// MIGRATE_DB=true applies pending migrations on startup (convenient in development);
+	// otherwise run "migrate up" before starting a new version
 	if os.Getenv("MIGRATE_DB") == "true" {
-		fmt.Println("[DEV] Running GORM AutoMigrate...")
-		err = db.AutoMigrate(&models.Tenant{}, &models.User{}, &models.Channel{})
+		applied, err := migrator.Up(0)
 		if err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
-		fmt.Println("Database connected and migrated (AutoMigrate enabled)")
-	} else {
-		fmt.Println("Database connected (no migration performed)")
+		fmt.Printf("Database connected; applied %d migration(s)\n", len(applied))
+		return
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		log.Fatalf("Failed to check migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		log.Printf("WARNING: %d pending migration(s); run \"migrate up\" to apply them", len(pending))
 	}
+	fmt.Println("Database connected")
+}
+
+// Open connects to the PostgreSQL database at dsn without migrating it
+func Open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+}
+
+// AutoMigrate creates or updates the tables for every model. It sets up the
+// embedded SQLite database (mock mode and tests); PostgreSQL schemas are
+// managed by the versioned migrations in db/migrations.
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Tenant{}, &models.User{}, &models.TenantMember{}, &models.Channel{},
+		&models.ChatChannel{}, &models.ChatMember{}, &models.Message{},
+		&models.RefreshToken{}, &models.RevokedToken{}, &models.PermissionOverride{},
+		&models.TenantRole{}, &models.Invite{}, &models.ChannelMember{}, &models.MessageRevision{},
+		&models.ThreadParticipant{}, &models.Reaction{},
+		&models.Attachment{}, &models.AttachmentPolicy{}, &models.Mention{})
 }
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+)
+
+// Handlers only soft-delete, so these constraints guard hard deletes made
+// outside the API (purges, manual cleanup)
+func TestForeignKeys(t *testing.T) {
+	conn, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreate := func(value interface{}) {
+		t.Helper()
+		if err := conn.Create(value).Error; err != nil {
+			t.Fatalf("create %T: %v", value, err)
+		}
+	}
+	tenant := models.Tenant{Name: "Acme"}
+	mustCreate(&tenant)
+	creator := models.User{Name: "Creator", Email: "creator@acme.test", Password: "x", TenantID: tenant.ID}
+	member := models.User{Name: "Member", Email: "member@acme.test", Password: "x", TenantID: tenant.ID}
+	mustCreate(&creator)
+	mustCreate(&member)
+	channel := models.Channel{Name: "general", StreamID: "acme-general", TenantID: tenant.ID, CreatedBy: creator.ID}
+	mustCreate(&channel)
+	mustCreate(&models.ChannelMember{ChannelID: channel.ID, UserID: member.ID})
+	mustCreate(&models.RefreshToken{UserID: member.ID, TenantID: tenant.ID, TokenHash: "hash"})
+
+	if err := conn.Unscoped().Delete(&tenant).Error; err == nil {
+		t.Error("deleted a tenant that still has members and channels")
+	}
+	if err := conn.Unscoped().Delete(&creator).Error; err == nil {
+		t.Error("deleted a user who created a channel")
+	}
+
+	// Deleting a user takes their memberships and sessions along
+	if err := conn.Unscoped().Delete(&member).Error; err != nil {
+		t.Fatalf("delete member: %v", err)
+	}
+	for _, model := range []interface{}{&models.TenantMember{}, &models.ChannelMember{}, &models.RefreshToken{}} {
+		var count int64
+		if err := conn.Model(model).Where("user_id = ?", member.ID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%T rows of the deleted user left behind: %d", model, count)
+		}
+	}
+
+	// Once its channels and members are gone the tenant can go too
+	for _, value := range []interface{}{&channel, &creator, &tenant} {
+		if err := conn.Unscoped().Delete(value).Error; err != nil {
+			t.Fatalf("delete %T: %v", value, err)
+		}
+	}
+}
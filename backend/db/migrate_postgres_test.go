@@ -0,0 +1,247 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// postgresSchema connects to TEST_DATABASE_URL and points the connection at a
+// schema of its own, dropped when the test ends. The test is skipped when the
+// variable is not set.
+func postgresSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection, so that search_path holds for every statement
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := conn.Exec(`CREATE SCHEMA "` + schema + `"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Exec(`DROP SCHEMA "` + schema + `" CASCADE`) })
+	if err := conn.Exec(`SET search_path TO "` + schema + `"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// TestMigrationsOnPostgres runs the embedded migrations up, down and up again
+// against TEST_DATABASE_URL, with users in between so that the data
+// migrations have rows to move.
+func TestMigrationsOnPostgres(t *testing.T) {
+	conn := postgresSchema(t)
+	exec := func(sql string, args ...interface{}) {
+		t.Helper()
+		if err := conn.Exec(sql, args...).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(conn, migrations)
+	if _, err := m.Up(3); err != nil {
+		t.Fatalf("up to 0003: %v", err)
+	}
+	const (
+		tenantA = "aaaaaaaa-0000-0000-0000-000000000001"
+		tenantB = "bbbbbbbb-0000-0000-0000-000000000002"
+		userID  = "cccccccc-0000-0000-0000-000000000003"
+	)
+	exec(`INSERT INTO "tenants" ("id", "name") VALUES (?, 'A'), (?, 'B')`, tenantA, tenantB)
+	exec(`INSERT INTO "users" ("id", "email", "password", "role", "tenant_id") VALUES (?, 'u@a.test', 'x', 'MODERATOR', ?)`, userID, tenantA)
+
+	if _, err := m.Up(1); err != nil {
+		t.Fatalf("up 0004: %v", err)
+	}
+	var role string
+	if err := conn.Raw(`SELECT "role" FROM "tenant_members" WHERE "tenant_id" = ? AND "user_id" = ?`, tenantA, userID).Scan(&role).Error; err != nil || role != "MODERATOR" {
+		t.Fatalf("membership role = %q, %v", role, err)
+	}
+	// A later membership in B; going down keeps the first one
+	exec(`INSERT INTO "tenant_members" ("tenant_id", "user_id", "role", "joined_at") VALUES (?, ?, 'ADMIN', ?)`,
+		tenantB, userID, time.Now().Add(time.Hour))
+
+	if _, err := m.Down(1); err != nil {
+		t.Fatalf("down 0004: %v", err)
+	}
+	var user struct {
+		TenantID string
+		Role     string
+	}
+	if err := conn.Raw(`SELECT "tenant_id", "role" FROM "users" WHERE "id" = ?`, userID).Scan(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TenantID != tenantA || user.Role != "MODERATOR" {
+		t.Errorf("user after down = %+v, want tenant A as MODERATOR", user)
+	}
+
+	if _, err := m.Down(len(migrations)); err != nil {
+		t.Fatalf("down to nothing: %v", err)
+	}
+	if conn.Migrator().HasTable("users") {
+		t.Error("users table left after reverting every migration")
+	}
+	if _, err := m.Up(0); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if pending, err := m.Pending(); err != nil || len(pending) != 0 {
+		t.Errorf("pending after up = %d, %v", len(pending), err)
+	}
+}
+
+// The tables as the baseline MIGRATE_DB=true AutoMigrate created them.
+type (
+	baselineTenant struct {
+		ID   string `gorm:"type:uuid;primaryKey"`
+		Name string `gorm:"uniqueIndex;not null"`
+	}
+	baselineUser struct {
+		ID       string `gorm:"type:uuid;primaryKey"`
+		Email    string `gorm:"uniqueIndex;not null"`
+		Name     string
+		Password string `gorm:"not null"`
+		Role     string `gorm:"default:MEMBER"`
+		TenantID string
+	}
+	baselineChannel struct {
+		ID          string `gorm:"type:uuid;primaryKey"`
+		StreamID    string `gorm:"uniqueIndex;not null"`
+		Name        string
+		Description string
+		TenantID    string
+		CreatedBy   string
+	}
+)
+
+func (baselineTenant) TableName() string  { return "tenants" }
+func (baselineUser) TableName() string    { return "users" }
+func (baselineChannel) TableName() string { return "channels" }
+
+// TestMigrationsAdoptBaselineSchema runs the migrations on a database set up
+// by the baseline AutoMigrate and checks that its rows pick up the columns
+// added since.
+func TestMigrationsAdoptBaselineSchema(t *testing.T) {
+	conn := postgresSchema(t)
+	if err := conn.AutoMigrate(&baselineTenant{}, &baselineUser{}, &baselineChannel{}); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		tenantID  = "aaaaaaaa-0000-0000-0000-000000000001"
+		userID    = "cccccccc-0000-0000-0000-000000000003"
+		channelID = "dddddddd-0000-0000-0000-000000000004"
+	)
+	for _, row := range []interface{}{
+		&baselineTenant{ID: tenantID, Name: "A"},
+		&baselineUser{ID: userID, Email: "u@a.test", Password: "x", Role: "ADMIN", TenantID: tenantID},
+		&baselineChannel{ID: channelID, StreamID: "general", Name: "general", TenantID: tenantID, CreatedBy: userID},
+	} {
+		if err := conn.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMigrator(conn, migrations).Up(0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	var user struct {
+		TokenVersion int
+		Role         string
+	}
+	if err := conn.Raw(`SELECT "u"."token_version", "m"."role" FROM "users" "u"
+		JOIN "tenant_members" "m" ON "m"."user_id" = "u"."id" AND "m"."tenant_id" = ?
+		WHERE "u"."id" = ?`, tenantID, userID).Scan(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TokenVersion != 0 || user.Role != "ADMIN" {
+		t.Errorf("user after up = %+v, want token version 0 as ADMIN", user)
+	}
+	var channel struct {
+		Type              string
+		AllowGuestPosting bool
+		DeletedAt         *time.Time
+	}
+	if err := conn.Raw(`SELECT "type", "allow_guest_posting", "deleted_at" FROM "channels" WHERE "id" = ?`, channelID).
+		Scan(&channel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if channel.Type != "public" || channel.AllowGuestPosting || channel.DeletedAt != nil {
+		t.Errorf("channel after up = %+v, want a public channel", channel)
+	}
+	// A second direct conversation between the same pair must still collide
+	err = conn.Exec(`INSERT INTO "channels" ("id", "stream_id", "tenant_id", "type", "dm_key") VALUES
+		(gen_random_uuid(), 'dm-1', ?, 'dm', 'k'), (gen_random_uuid(), 'dm-2', ?, 'dm', 'k')`, tenantID, tenantID).Error
+	if err == nil {
+		t.Error("duplicate dm_key accepted after adopting the baseline schema")
+	}
+}
+
+// TestMigrationsStopOnRowsWithoutTenant checks that users and channels the
+// foreign keys cannot accept are reported instead of failing halfway.
+func TestMigrationsStopOnRowsWithoutTenant(t *testing.T) {
+	conn := postgresSchema(t)
+	if err := conn.AutoMigrate(&baselineTenant{}, &baselineUser{}, &baselineChannel{}); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		tenantID  = "aaaaaaaa-0000-0000-0000-000000000001"
+		gone      = "bbbbbbbb-0000-0000-0000-000000000002"
+		userID    = "cccccccc-0000-0000-0000-000000000003"
+		channelID = "dddddddd-0000-0000-0000-000000000004"
+	)
+	for _, row := range []interface{}{
+		&baselineTenant{ID: tenantID, Name: "A"},
+		&baselineUser{ID: userID, Email: "u@a.test", Password: "x"},
+		&baselineChannel{ID: channelID, StreamID: "general", Name: "general", TenantID: gone},
+	} {
+		if err := conn.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(conn, migrations)
+	_, err = m.Up(0)
+	if err == nil {
+		t.Fatal("migrations applied with a user and a channel outside any tenant")
+	}
+	for _, id := range []string{userID, channelID} {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("error %q does not list %s", err, id)
+		}
+	}
+
+	// Once they are fixed the migrations go through
+	if err := conn.Model(&baselineUser{ID: userID}).Update("tenant_id", tenantID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Delete(&baselineChannel{ID: channelID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(0); err != nil {
+		t.Fatalf("up after fixing the rows: %v", err)
+	}
+}
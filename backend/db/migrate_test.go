@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migration %d is out of order", migrations[i].Version)
+		}
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0001_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")},
+		"0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"0002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id integer PRIMARY KEY);")},
+		"0002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets;")},
+		"0003_broken.up.sql":    {Data: []byte("CREATE TABLE widgets (id integer);")},
+		"0003_broken.down.sql":  {Data: []byte("SELECT 1;")},
+		"README.md":             {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMigrator(conn, migrations)
+
+	if done, err := m.Up(2); err != nil || len(done) != 2 {
+		t.Fatalf("Up(2) = %d, %v", len(done), err)
+	}
+	// 0003 fails, and its transaction leaves no trace
+	if done, err := m.Up(0); err == nil || len(done) != 0 {
+		t.Fatalf("Up(0) = %d, %v; want an error", len(done), err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 3 || status[0].AppliedAt == nil || status[1].AppliedAt == nil || status[2].AppliedAt != nil {
+		t.Errorf("status = %+v", status)
+	}
+
+	if done, err := m.Down(1); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("Down(1) = %+v, %v", done, err)
+	}
+	if conn.Migrator().HasTable("gadgets") || !conn.Migrator().HasTable("widgets") {
+		t.Error("Down(1) did not revert only the last migration")
+	}
+	if pending, _ := m.Pending(); len(pending) != 2 {
+		t.Errorf("pending = %d, want 2", len(pending))
+	}
+}
+
+func TestLoadMigrationsNeedsBothDirections(t *testing.T) {
+	_, err := LoadMigrations(fstest.MapFS{"0001_widgets.up.sql": {Data: []byte("SELECT 1;")}})
+	if err == nil {
+		t.Error("want an error for a migration without a down file")
+	}
+}
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS "mentions";
+DROP TABLE IF EXISTS "attachment_policies";
+DROP TABLE IF EXISTS "attachments";
+DROP TABLE IF EXISTS "reactions";
+DROP TABLE IF EXISTS "thread_participants";
+DROP TABLE IF EXISTS "message_revisions";
+DROP TABLE IF EXISTS "channel_members";
+DROP TABLE IF EXISTS "invites";
+DROP TABLE IF EXISTS "tenant_roles";
+DROP TABLE IF EXISTS "permission_overrides";
+DROP TABLE IF EXISTS "revoked_tokens";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "messages";
+DROP TABLE IF EXISTS "chat_members";
+DROP TABLE IF EXISTS "chat_channels";
+DROP TABLE IF EXISTS "channels";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "tenants";
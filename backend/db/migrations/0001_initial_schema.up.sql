@@ -0,0 +1,75 @@
+-- Baseline schema. Tables and indexes are created only if missing, so
+-- databases previously set up by MIGRATE_DB=true AutoMigrate are adopted:
+-- the tenants, users and channels tables it created are kept and given the
+-- columns added since, with the same defaults as a fresh install.
+
+CREATE TABLE IF NOT EXISTS "tenants" ("id" uuid,"name" text NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tenants_name" ON "tenants" ("name");
+
+CREATE TABLE IF NOT EXISTS "users" ("id" uuid,"email" text NOT NULL,"name" text,"password" text NOT NULL,"role" text DEFAULT 'MEMBER',"tenant_id" text,"token_version" bigint NOT NULL DEFAULT 0,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "token_version" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "channels" ("id" uuid,"stream_id" text NOT NULL,"name" text,"description" text,"tenant_id" text,"created_by" text,"type" text NOT NULL DEFAULT 'public',"dm_key" text,"allow_guest_posting" boolean NOT NULL DEFAULT false,"created_at" timestamptz,"archived_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_channels_stream_id" ON "channels" ("stream_id");
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "type" text NOT NULL DEFAULT 'public';
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "dm_key" text;
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "allow_guest_posting" boolean NOT NULL DEFAULT false;
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "archived_at" timestamptz;
+ALTER TABLE "channels" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_channels_deleted_at" ON "channels" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_channels_dm_key" ON "channels" ("dm_key");
+
+CREATE TABLE IF NOT EXISTS "chat_channels" ("id" text,"tenant_id" text,"name" text,"description" text,"created_by" text,"frozen" boolean NOT NULL DEFAULT false,"created_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_chat_channels_deleted_at" ON "chat_channels" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_chat_channels_tenant_id" ON "chat_channels" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "chat_members" ("channel_id" text,"user_id" text,"created_at" timestamptz,PRIMARY KEY ("channel_id","user_id"));
+
+CREATE TABLE IF NOT EXISTS "messages" ("id" uuid,"channel_id" text NOT NULL,"user_id" text NOT NULL,"text" text,"parent_id" text,"reply_count" bigint NOT NULL DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,"edited_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_messages_channel_id" ON "messages" ("channel_id");
+CREATE INDEX IF NOT EXISTS "idx_messages_created_at" ON "messages" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_messages_parent_id" ON "messages" ("parent_id");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" ("id" uuid,"user_id" text NOT NULL,"token_hash" text NOT NULL,"expires_at" timestamptz,"revoked_at" timestamptz,"replaced_by" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" ("jti" text,"expires_at" timestamptz,PRIMARY KEY ("jti"));
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "permission_overrides" ("tenant_id" text,"role" text,"permission" text,"allowed" boolean NOT NULL,PRIMARY KEY ("tenant_id","role","permission"));
+
+CREATE TABLE IF NOT EXISTS "tenant_roles" ("id" uuid,"tenant_id" text NOT NULL,"name" text NOT NULL,"description" text,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tenant_role_name" ON "tenant_roles" ("tenant_id","name");
+
+CREATE TABLE IF NOT EXISTS "invites" ("id" uuid,"tenant_id" text NOT NULL,"email" text NOT NULL,"role" text NOT NULL,"invited_by" text,"expires_at" timestamptz,"accepted_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_invites_email" ON "invites" ("email");
+CREATE INDEX IF NOT EXISTS "idx_invites_tenant_id" ON "invites" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "channel_members" ("channel_id" uuid,"user_id" uuid,"role" text NOT NULL DEFAULT 'member',"joined_at" timestamptz,PRIMARY KEY ("channel_id","user_id"));
+CREATE INDEX IF NOT EXISTS "idx_channel_members_user_id" ON "channel_members" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "message_revisions" ("id" uuid,"message_id" text NOT NULL,"channel_id" text NOT NULL,"editor_id" text NOT NULL,"action" text NOT NULL,"text" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_message_revisions_message_id" ON "message_revisions" ("message_id");
+
+CREATE TABLE IF NOT EXISTS "thread_participants" ("parent_id" text,"user_id" text,"created_at" timestamptz,PRIMARY KEY ("parent_id","user_id"));
+
+CREATE TABLE IF NOT EXISTS "reactions" ("message_id" text,"user_id" text,"emoji" text,"channel_id" text NOT NULL,"created_at" timestamptz,PRIMARY KEY ("message_id","user_id","emoji"));
+CREATE INDEX IF NOT EXISTS "idx_reactions_channel_id" ON "reactions" ("channel_id");
+
+CREATE TABLE IF NOT EXISTS "attachments" ("id" uuid,"tenant_id" text NOT NULL,"channel_id" text NOT NULL,"message_id" text,"uploader_id" text NOT NULL,"filename" text NOT NULL,"content_type" text NOT NULL,"size" bigint NOT NULL,"storage_key" text NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_attachments_message_id" ON "attachments" ("message_id");
+CREATE INDEX IF NOT EXISTS "idx_attachments_channel_id" ON "attachments" ("channel_id");
+CREATE INDEX IF NOT EXISTS "idx_attachments_tenant_id" ON "attachments" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "attachment_policies" ("tenant_id" uuid,"max_bytes" bigint NOT NULL,"allowed_types" text NOT NULL,PRIMARY KEY ("tenant_id"));
+
+CREATE TABLE IF NOT EXISTS "mentions" ("id" uuid,"tenant_id" text NOT NULL,"user_id" text NOT NULL,"message_id" text NOT NULL,"channel_id" text NOT NULL,"author_id" text NOT NULL,"kind" text NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_mentions_user_id" ON "mentions" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mention_message_user" ON "mentions" ("message_id","user_id");
+CREATE INDEX IF NOT EXISTS "idx_mentions_tenant_id" ON "mentions" ("tenant_id");
+
+-- Full-text message search (services.LocalChat.SearchMessages)
+CREATE INDEX IF NOT EXISTS idx_messages_text_search ON messages USING GIN (to_tsvector('english', text));
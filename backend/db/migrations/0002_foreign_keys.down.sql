@@ -0,0 +1,23 @@
+ALTER TABLE "attachment_policies" DROP CONSTRAINT IF EXISTS "fk_attachment_policies_tenant";
+ALTER TABLE "tenant_roles" DROP CONSTRAINT IF EXISTS "fk_tenant_roles_tenant";
+ALTER TABLE "permission_overrides" DROP CONSTRAINT IF EXISTS "fk_permission_overrides_tenant";
+ALTER TABLE "invites" DROP CONSTRAINT IF EXISTS "fk_invites_tenant";
+ALTER TABLE "refresh_tokens" DROP CONSTRAINT IF EXISTS "fk_refresh_tokens_user";
+ALTER TABLE "channel_members" DROP CONSTRAINT IF EXISTS "fk_channel_members_user";
+ALTER TABLE "channel_members" DROP CONSTRAINT IF EXISTS "fk_channel_members_channel";
+ALTER TABLE "channels" DROP CONSTRAINT IF EXISTS "fk_channels_creator";
+ALTER TABLE "channels" DROP CONSTRAINT IF EXISTS "fk_channels_tenant";
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "fk_users_tenant";
+
+DROP INDEX IF EXISTS "idx_channels_tenant_id";
+DROP INDEX IF EXISTS "idx_users_tenant_id";
+ALTER TABLE "channels" ALTER COLUMN "tenant_id" DROP NOT NULL;
+ALTER TABLE "users" ALTER COLUMN "tenant_id" DROP NOT NULL;
+
+ALTER TABLE "tenant_roles" ALTER COLUMN "tenant_id" TYPE text;
+ALTER TABLE "permission_overrides" ALTER COLUMN "tenant_id" TYPE text;
+ALTER TABLE "invites" ALTER COLUMN "tenant_id" TYPE text;
+ALTER TABLE "refresh_tokens" ALTER COLUMN "user_id" TYPE text;
+ALTER TABLE "channels" ALTER COLUMN "created_by" TYPE text;
+ALTER TABLE "channels" ALTER COLUMN "tenant_id" TYPE text;
+ALTER TABLE "users" ALTER COLUMN "tenant_id" TYPE text;
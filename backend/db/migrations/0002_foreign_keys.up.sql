@@ -0,0 +1,70 @@
+-- Foreign keys between tenants, users and channels. Tenant and user
+-- references were plain text columns; they become uuid so they can be
+-- constrained, and rows left behind by earlier deletes are cleaned up first.
+-- Tenants, users and channels are soft deleted, so ON DELETE RESTRICT only
+-- guards against deletes made outside the application.
+
+ALTER TABLE "users" ALTER COLUMN "tenant_id" TYPE uuid USING NULLIF("tenant_id", '')::uuid;
+ALTER TABLE "channels" ALTER COLUMN "tenant_id" TYPE uuid USING NULLIF("tenant_id", '')::uuid;
+ALTER TABLE "channels" ALTER COLUMN "created_by" TYPE uuid USING NULLIF("created_by", '')::uuid;
+ALTER TABLE "refresh_tokens" ALTER COLUMN "user_id" TYPE uuid USING "user_id"::uuid;
+ALTER TABLE "invites" ALTER COLUMN "tenant_id" TYPE uuid USING "tenant_id"::uuid;
+ALTER TABLE "permission_overrides" ALTER COLUMN "tenant_id" TYPE uuid USING "tenant_id"::uuid;
+ALTER TABLE "tenant_roles" ALTER COLUMN "tenant_id" TYPE uuid USING "tenant_id"::uuid;
+
+-- Orphans: channels keep their history when the creator is gone, everything
+-- else that points at a missing row is dropped
+UPDATE "channels" SET "created_by" = NULL
+	WHERE "created_by" IS NOT NULL AND "created_by" NOT IN (SELECT "id" FROM "users");
+DELETE FROM "channel_members" WHERE "channel_id" NOT IN (SELECT "id" FROM "channels");
+DELETE FROM "channel_members" WHERE "user_id" NOT IN (SELECT "id" FROM "users");
+DELETE FROM "refresh_tokens" WHERE "user_id" NOT IN (SELECT "id" FROM "users");
+DELETE FROM "invites" WHERE "tenant_id" NOT IN (SELECT "id" FROM "tenants");
+DELETE FROM "permission_overrides" WHERE "tenant_id" NOT IN (SELECT "id" FROM "tenants");
+DELETE FROM "tenant_roles" WHERE "tenant_id" NOT IN (SELECT "id" FROM "tenants");
+DELETE FROM "attachment_policies" WHERE "tenant_id" NOT IN (SELECT "id" FROM "tenants");
+
+-- Users and channels without a tenant cannot be placed automatically; stop
+-- and list them so they can be assigned to a tenant or deleted by hand
+DO $$
+DECLARE
+	orphans text;
+BEGIN
+	SELECT string_agg("kind" || ' ' || "id", ', ') INTO orphans FROM (
+		SELECT 'user' AS "kind", "id" FROM "users"
+			WHERE "tenant_id" IS NULL OR "tenant_id" NOT IN (SELECT "id" FROM "tenants")
+		UNION ALL
+		SELECT 'channel', "id" FROM "channels"
+			WHERE "tenant_id" IS NULL OR "tenant_id" NOT IN (SELECT "id" FROM "tenants")
+	) AS "o";
+	IF orphans IS NOT NULL THEN
+		RAISE EXCEPTION 'rows without an existing tenant: %', orphans
+			USING HINT = 'Set their tenant_id to an existing tenant or delete them, then migrate again.';
+	END IF;
+END $$;
+
+ALTER TABLE "users" ALTER COLUMN "tenant_id" SET NOT NULL;
+ALTER TABLE "channels" ALTER COLUMN "tenant_id" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_users_tenant_id" ON "users" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_channels_tenant_id" ON "channels" ("tenant_id");
+
+ALTER TABLE "users" ADD CONSTRAINT "fk_users_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE RESTRICT;
+ALTER TABLE "channels" ADD CONSTRAINT "fk_channels_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE RESTRICT;
+ALTER TABLE "channels" ADD CONSTRAINT "fk_channels_creator"
+	FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE RESTRICT;
+ALTER TABLE "channel_members" ADD CONSTRAINT "fk_channel_members_channel"
+	FOREIGN KEY ("channel_id") REFERENCES "channels"("id") ON DELETE CASCADE;
+ALTER TABLE "channel_members" ADD CONSTRAINT "fk_channel_members_user"
+	FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+ALTER TABLE "refresh_tokens" ADD CONSTRAINT "fk_refresh_tokens_user"
+	FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+ALTER TABLE "invites" ADD CONSTRAINT "fk_invites_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE;
+ALTER TABLE "permission_overrides" ADD CONSTRAINT "fk_permission_overrides_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE;
+ALTER TABLE "tenant_roles" ADD CONSTRAINT "fk_tenant_roles_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE;
+ALTER TABLE "attachment_policies" ADD CONSTRAINT "fk_attachment_policies_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE;
@@ -0,0 +1,18 @@
+-- Users go back to a single tenant: the first one they joined, preferring
+-- memberships that were not removed. The columns are added without
+-- constraints and backfilled before NOT NULL is restored; accounts without
+-- any membership cannot be represented, so they make this migration fail.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "tenant_id" uuid;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "role" text;
+UPDATE "users" SET "tenant_id" = m."tenant_id", "role" = m."role"
+	FROM (SELECT DISTINCT ON ("user_id") "user_id", "tenant_id", "role" FROM "tenant_members"
+		ORDER BY "user_id", "removed_at" NULLS FIRST, "joined_at") m
+	WHERE m."user_id" = "users"."id";
+ALTER TABLE "users" ALTER COLUMN "role" SET DEFAULT 'MEMBER';
+ALTER TABLE "users" ALTER COLUMN "tenant_id" SET NOT NULL;
+CREATE INDEX IF NOT EXISTS "idx_users_tenant_id" ON "users" ("tenant_id");
+ALTER TABLE "users" ADD CONSTRAINT "fk_users_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE RESTRICT;
+
+ALTER TABLE "refresh_tokens" DROP COLUMN IF EXISTS "tenant_id";
+DROP TABLE IF EXISTS "tenant_members";
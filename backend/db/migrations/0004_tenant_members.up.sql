@@ -0,0 +1,21 @@
+-- Users belong to tenants through tenant_members, with a role per tenant.
+-- Each existing user becomes a member of the tenant they were created in.
+CREATE TABLE IF NOT EXISTS "tenant_members" ("tenant_id" uuid,"user_id" uuid,"role" text NOT NULL DEFAULT 'MEMBER',"joined_at" timestamptz,"removed_at" timestamptz,PRIMARY KEY ("tenant_id","user_id"));
+CREATE INDEX IF NOT EXISTS "idx_tenant_members_user_id" ON "tenant_members" ("user_id");
+ALTER TABLE "tenant_members" ADD CONSTRAINT "fk_tenant_members_tenant"
+	FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE RESTRICT;
+ALTER TABLE "tenant_members" ADD CONSTRAINT "fk_tenant_members_user"
+	FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+
+INSERT INTO "tenant_members" ("tenant_id", "user_id", "role", "joined_at", "removed_at")
+	SELECT "tenant_id", "id", COALESCE("role", 'MEMBER'), now(), "deleted_at" FROM "users";
+
+-- Refresh tokens remember the tenant they sign in to
+ALTER TABLE "refresh_tokens" ADD COLUMN IF NOT EXISTS "tenant_id" uuid;
+UPDATE "refresh_tokens" SET "tenant_id" = "users"."tenant_id"
+	FROM "users" WHERE "users"."id" = "refresh_tokens"."user_id";
+
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "fk_users_tenant";
+DROP INDEX IF EXISTS "idx_users_tenant_id";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "role";
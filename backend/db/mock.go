@@ -0,0 +1,166 @@
+// db/mock.go - Embedded demo database for DATABASE_URL=mock
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// MockPassword is the password of every seeded demo user
+const MockPassword = "password"
+
+// connectMock opens an in-memory SQLite database, migrates it and seeds demo data
+func connectMock() {
+	db, err := OpenMemory()
+	if err != nil {
+		log.Fatalf("Failed to open mock database: %v", err)
+	}
+	if err := Seed(db); err != nil {
+		log.Fatalf("Failed to seed mock database: %v", err)
+	}
+	DB = db
+	Mock = true
+	fmt.Println("[MOCK] Using in-memory database with demo data; every user's password is " + MockPassword)
+}
+
+// OpenMemory opens a fresh, migrated in-memory SQLite database
+func OpenMemory() (*gorm.DB, error) {
+	// A single connection keeps every query on the same in-memory database
+	db, err := gorm.Open(sqlite.Open("file::memory:?_pragma=foreign_keys(1)"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := AutoMigrate(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+type seedUser struct {
+	name  string
+	email string
+	role  models.Role
+}
+
+type seedTenant struct {
+	name     string
+	users    []seedUser
+	channels []string
+}
+
+var demoTenants = []seedTenant{
+	{
+		name: "Acme Corp",
+		users: []seedUser{
+			{"Alice Admin", "alice@acme.test", models.RoleAdmin},
+			{"Mark Moderator", "mark@acme.test", models.RoleModerator},
+			{"Mia Member", "mia@acme.test", models.RoleMember},
+			{"Gus Guest", "gus@acme.test", models.RoleGuest},
+		},
+		channels: []string{"general", "random"},
+	},
+	{
+		name: "Globex",
+		users: []seedUser{
+			{"Grace Admin", "grace@globex.test", models.RoleAdmin},
+			{"Gary Member", "gary@globex.test", models.RoleMember},
+			// Alice also belongs to Acme Corp and can switch between both
+			{"Alice Admin", "alice@acme.test", models.RoleMember},
+		},
+		channels: []string{"general"},
+	},
+}
+
+// Seed fills db with the demo tenants, users, channels and messages
+func Seed(db *gorm.DB) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(MockPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		accounts := make(map[string]models.User)
+		for _, st := range demoTenants {
+			tenant := models.Tenant{Name: st.name}
+			if err := tx.Create(&tenant).Error; err != nil {
+				return err
+			}
+			var users []models.User
+			for _, su := range st.users {
+				if account, ok := accounts[su.email]; ok {
+					member := models.TenantMember{TenantID: tenant.ID, UserID: account.ID, Role: su.role}
+					if err := tx.Create(&member).Error; err != nil {
+						return err
+					}
+					users = append(users, account)
+					continue
+				}
+				user := models.User{
+					Name:     su.name,
+					Email:    su.email,
+					Password: string(hash),
+					Role:     su.role,
+					TenantID: tenant.ID,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				accounts[su.email] = user
+				users = append(users, user)
+			}
+			creator := users[0]
+			for _, name := range st.channels {
+				chatChannel := models.ChatChannel{
+					ID:          tenant.ID[:8] + "-" + name,
+					TenantID:    tenant.ID,
+					Name:        name,
+					Description: "Demo #" + name + " channel for " + tenant.Name,
+					CreatedBy:   creator.ID,
+				}
+				if err := tx.Create(&chatChannel).Error; err != nil {
+					return err
+				}
+				channel := models.Channel{
+					StreamID:    chatChannel.ID,
+					Name:        chatChannel.Name,
+					Description: chatChannel.Description,
+					TenantID:    tenant.ID,
+					CreatedBy:   creator.ID,
+				}
+				if err := tx.Create(&channel).Error; err != nil {
+					return err
+				}
+				for _, u := range users {
+					if err := tx.Create(&models.ChatMember{ChannelID: chatChannel.ID, UserID: u.ID}).Error; err != nil {
+						return err
+					}
+					role := models.ChannelRoleMember
+					if u.ID == creator.ID {
+						role = models.ChannelRoleOwner
+					}
+					if err := tx.Create(&models.ChannelMember{ChannelID: channel.ID, UserID: u.ID, Role: role}).Error; err != nil {
+						return err
+					}
+				}
+				welcome := models.Message{
+					ChannelID: chatChannel.ID,
+					UserID:    creator.ID,
+					Text:      "Welcome to #" + name + "!",
+				}
+				if err := tx.Create(&welcome).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,327 @@
+// db/paginate.go - Keyset pagination, sorting and search for list endpoints
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// DefaultPageSize is used when ListOptions.Limit is zero
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page a list endpoint returns
+	MaxPageSize = 100
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor that was not issued for this sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned for a sort field the list does not support
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// SortField is a column a list can be sorted by
+type SortField struct {
+	Column string
+	Time   bool // the column holds timestamps
+}
+
+// ListSpec describes the sorting and searching a list endpoint supports
+type ListSpec struct {
+	Sorts         map[string]SortField // by API field name
+	DefaultSort   string               // API field name, "-" prefix for descending
+	SearchColumns []string             // matched case-insensitively by ListOptions.Search
+}
+
+// ListOptions are the client's paging, sorting and search parameters
+type ListOptions struct {
+	Limit  int
+	Cursor string // NextCursor of the previous page
+	Sort   string // API field name, "-" prefix for descending
+	Search string
+}
+
+// Page is one page of a list. Total counts every item matching the filters.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int64   `json:"total"`
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Paginate returns a page of the rows of query, which may already carry
+// filters (tenant scope, role, ...). Pages are ordered by the sort field and
+// then by primary key, so cursors stay stable when values repeat.
+func Paginate[T any](query *gorm.DB, spec ListSpec, opts ListOptions) (*Page[T], error) {
+	sortName, desc, field, limit, err := spec.resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var model T
+	query = query.Model(&model)
+	if opts.Search != "" && len(spec.SearchColumns) > 0 {
+		pattern := "%" + strings.ToLower(opts.Search) + "%"
+		conds := make([]string, len(spec.SearchColumns))
+		args := make([]interface{}, len(spec.SearchColumns))
+		for i, col := range spec.SearchColumns {
+			conds[i] = "LOWER(" + col + ") LIKE ?"
+			args[i] = pattern
+		}
+		query = query.Where(strings.Join(conds, " OR "), args...)
+	}
+
+	page := &Page[T]{Items: []T{}}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	rows := query.Session(&gorm.Session{})
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+	if opts.Cursor != "" {
+		cur, err := decodeCursor(opts.Cursor, sortName)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = cur.Value
+		if field.Time {
+			t, err := time.Parse(time.RFC3339Nano, cur.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			value = t
+		}
+		rows = rows.Where("("+field.Column+" "+op+" ? OR ("+field.Column+" = ? AND id "+op+" ?))", value, value, cur.ID)
+	}
+	err = rows.Order(field.Column + " " + dir).Order("id " + dir).Limit(limit + 1).Find(&page.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		next, err := encodeCursor(query, page.Items[limit-1], field, sortName)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// resolve returns the sort order and page size requested by opts
+func (spec ListSpec) resolve(opts ListOptions) (sortName string, desc bool, field SortField, limit int, err error) {
+	sortName = opts.Sort
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	desc = strings.HasPrefix(sortName, "-")
+	field, ok := spec.Sorts[strings.TrimPrefix(sortName, "-")]
+	if !ok {
+		return "", false, field, 0, ErrInvalidSort
+	}
+	limit = opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return sortName, desc, field, limit, nil
+}
+
+// encodeCursor builds the cursor pointing just after item
+func encodeCursor(query *gorm.DB, item interface{}, field SortField, sortName string) (string, error) {
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(item); err != nil {
+		return "", err
+	}
+	return cursorAfter(query.Statement.Context, stmt.Schema, reflect.ValueOf(item), field, sortName)
+}
+
+func cursorAfter(ctx context.Context, s *schema.Schema, rv reflect.Value, field SortField, sortName string) (string, error) {
+	sortField := s.LookUpField(columnName(field.Column))
+	idField := s.LookUpField("id")
+	if sortField == nil || idField == nil {
+		return "", ErrInvalidSort
+	}
+	v, _ := sortField.ValueOf(ctx, rv)
+	id, _ := idField.ValueOf(ctx, rv)
+	cur := cursor{Sort: sortName, ID: toString(id)}
+	if t, ok := sortValue(v).(time.Time); ok {
+		cur.Value = t.Format(time.RFC3339Nano)
+	} else {
+		cur.Value = toString(v)
+	}
+	raw, err := json.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s, sortName string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur cursor
+	if err := json.Unmarshal(raw, &cur); err != nil || cur.Sort != sortName {
+		return nil, ErrInvalidCursor
+	}
+	return &cur, nil
+}
+
+func toString(v interface{}) string {
+	// Sort and ID columns are strings or named string types such as models.Role
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+		return rv.String()
+	}
+	return ""
+}
+
+// columnName strips the table qualifier of a ListSpec column
+func columnName(column string) string {
+	return column[strings.LastIndex(column, ".")+1:]
+}
+
+// sortValue turns timestamp columns (time.Time, *time.Time, gorm.DeletedAt)
+// into time.Time, the zero time standing for NULL, and other values into
+// strings
+func sortValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t == nil {
+			return time.Time{}
+		}
+		return *t
+	case gorm.DeletedAt:
+		return t.Time
+	}
+	return toString(v)
+}
+
+// compareSortValues orders two values returned by sortValue
+func compareSortValues(a, b interface{}) int {
+	if ta, ok := a.(time.Time); ok {
+		tb, _ := b.(time.Time)
+		return ta.Compare(tb)
+	}
+	return strings.Compare(a.(string), b.(string))
+}
+
+var sliceSchemas sync.Map
+
+// PaginateSlice returns a page of items with the same sorting, search and
+// cursors as Paginate, for stores that keep rows in memory. Items are matched
+// by the gorm column names of T. Filters must already be applied.
+func PaginateSlice[T any](items []T, spec ListSpec, opts ListOptions) (*Page[T], error) {
+	sortName, desc, field, limit, err := spec.resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.Parse(new(T), &sliceSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	sortField := s.LookUpField(columnName(field.Column))
+	idField := s.LookUpField("id")
+	if sortField == nil || idField == nil {
+		return nil, ErrInvalidSort
+	}
+	ctx := context.Background()
+	valueOf := func(item T, f *schema.Field) interface{} {
+		v, _ := f.ValueOf(ctx, reflect.ValueOf(item))
+		return sortValue(v)
+	}
+
+	matched := []T{}
+	search := strings.ToLower(opts.Search)
+	for _, item := range items {
+		if search == "" || len(spec.SearchColumns) == 0 {
+			matched = append(matched, item)
+			continue
+		}
+		for _, col := range spec.SearchColumns {
+			f := s.LookUpField(columnName(col))
+			if f == nil {
+				continue
+			}
+			if v, ok := valueOf(item, f).(string); ok && strings.Contains(strings.ToLower(v), search) {
+				matched = append(matched, item)
+				break
+			}
+		}
+	}
+	// Ordered by the sort field, then by primary key
+	order := func(a, b T) int {
+		if c := compareSortValues(valueOf(a, sortField), valueOf(b, sortField)); c != 0 {
+			return c
+		}
+		return compareSortValues(valueOf(a, idField), valueOf(b, idField))
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if desc {
+			return order(matched[i], matched[j]) > 0
+		}
+		return order(matched[i], matched[j]) < 0
+	})
+
+	page := &Page[T]{Items: []T{}, Total: int64(len(matched))}
+	start := 0
+	if opts.Cursor != "" {
+		cur, err := decodeCursor(opts.Cursor, sortName)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = cur.Value
+		if field.Time {
+			t, err := time.Parse(time.RFC3339Nano, cur.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			value = t
+		}
+		for start < len(matched) {
+			c := compareSortValues(valueOf(matched[start], sortField), value)
+			if c == 0 {
+				c = compareSortValues(valueOf(matched[start], idField), cur.ID)
+			}
+			if (!desc && c > 0) || (desc && c < 0) {
+				break
+			}
+			start++
+		}
+	}
+	end := start + limit
+	if end >= len(matched) {
+		page.Items = append(page.Items, matched[start:]...)
+		return page, nil
+	}
+	page.Items = append(page.Items, matched[start:end]...)
+	next, err := cursorAfter(ctx, s, reflect.ValueOf(matched[end-1]), field, sortName)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = &next
+	return page, nil
+}
@@ -0,0 +1,47 @@
+// db/scope.go - Tenant-scoped queries
+package db
+
+import (
+	"context"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+// TenantScope restricts a query to the rows of one tenant
+func TenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("tenant_id = ?", tenantID)
+	}
+}
+
+// ForTenant returns a session whose queries only see tenantID's rows.
+// The session can be reused for several queries; rows of other tenants
+// behave as if they did not exist (gorm.ErrRecordNotFound).
+func ForTenant(ctx context.Context, tenantID string) *gorm.DB {
+	return DB.WithContext(ctx).Scopes(TenantScope(tenantID)).Session(&gorm.Session{})
+}
+
+// TenantUsers returns a session over the users that are members of tenantID,
+// with User.TenantID and User.Role filled from their membership. Users
+// removed from the tenant are skipped unless removed is true, in which case
+// only they are returned, with User.RemovedAt set.
+func TenantUsers(ctx context.Context, tenantID string, removed bool) *gorm.DB {
+	return Members(DB.WithContext(ctx), tenantID, removed)
+}
+
+// Members restricts query to the users of tenantID, as described for
+// TenantUsers. Unlike a Gorm scope it applies the select right away, so
+// Count still works on the result.
+func Members(query *gorm.DB, tenantID string, removed bool) *gorm.DB {
+	cond := "tenant_members.removed_at IS NULL"
+	if removed {
+		// Removing a user from their last tenant also deletes the account
+		cond = "tenant_members.removed_at IS NOT NULL"
+		query = query.Unscoped()
+	}
+	return query.Model(&models.User{}).
+		Select("users.*", "tenant_members.tenant_id", "tenant_members.role", "tenant_members.removed_at").
+		Joins("JOIN tenant_members ON tenant_members.user_id = users.id AND tenant_members.tenant_id = ? AND "+cond, tenantID).
+		Session(&gorm.Session{})
+}
@@ -5,6 +5,7 @@ module.exports = {
       script: 'main.go',
       exec_interpreter: 'go',
       env: {
+        CHAT_PROVIDER: 'stream',
         STREAM_API_KEY: '',
         STREAM_API_SECRET: '',
         DATABASE_URL: '',
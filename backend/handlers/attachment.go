@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// AttachmentPolicyRequest sets the caller's tenant upload limits
+type AttachmentPolicyRequest struct {
+	MaxBytes     int64    `json:"max_bytes" binding:"required,min=1"`
+	AllowedTypes []string `json:"allowed_types" binding:"required,min=1"` // MIME types such as image/png or image/*
+}
+
+// UploadAttachment uploads a file to a channel
+// @Summary Upload an attachment
+// @Description Uploads a file (multipart field "file") to a channel of the caller's tenant. The caller must be allowed to post in the channel, and the file must fit the tenant's attachment policy; the content type is detected from the file. Send the returned ID in attachment_ids of POST /messages.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param stream_id formData string true "Stream channel ID"
+// @Param file formData file true "File"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /attachments [post]
+func (s *Server) UploadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	policy, err := s.attachmentPolicy(ctx, c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch attachment policy"})
+		return
+	}
+	// Leave room for the multipart envelope; the file size is checked below
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Files may not exceed %d bytes", policy.MaxBytes)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		}
+		return
+	}
+	if header.Size > policy.MaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Files may not exceed %d bytes", policy.MaxBytes)})
+		return
+	}
+	channel, role, ok := s.channelForCaller(c, c.PostForm("stream_id"))
+	if !ok || !canPost(c, channel, role) {
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	defer file.Close()
+	// Trust the file's contents rather than the client's Content-Type
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read file"})
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if !policy.Allows(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Files of type " + contentType + " are not allowed"})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read file"})
+		return
+	}
+	att := models.Attachment{
+		TenantID:    channel.TenantID,
+		ChannelID:   channel.StreamID,
+		UploaderID:  c.GetString("user_id"),
+		Filename:    header.Filename,
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+	if err := services.SaveAttachment(ctx, &att, file); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not store attachment"})
+		return
+	}
+	att.URL = services.AttachmentURL(&att, time.Now().Add(services.AttachmentURLTTL()))
+	c.JSON(http.StatusCreated, att)
+}
+
+// DownloadAttachment serves an attachment through a signed URL
+// @Summary Download an attachment
+// @Description Serves the file of an attachment. The URL is the signed, expiring url returned with the attachment; no Authorization header is needed.
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path string true "Attachment ID"
+// @Param expires query string true "Expiry (Unix time)"
+// @Param signature query string true "Signature"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id}/download [get]
+func (s *Server) DownloadAttachment(c *gin.Context) {
+	att, err := s.Attachments.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch attachment"})
+		}
+		return
+	}
+	if !services.VerifyAttachmentURL(att, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+	body, err := services.OpenAttachment(c.Request.Context(), att)
+	if errors.Is(err, services.ErrBlobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read attachment"})
+		return
+	}
+	defer body.Close()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": att.Filename})
+	c.DataFromReader(http.StatusOK, att.Size, att.ContentType, body, map[string]string{
+		"Content-Disposition":    disposition,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// GetAttachmentPolicy returns the caller's tenant upload limits
+// @Summary Get attachment policy
+// @Description Returns the maximum file size and the accepted MIME types for uploads in the caller's tenant
+// @Tags attachments
+// @Produce json
+// @Success 200 {object} models.AttachmentPolicy
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /attachment-policy [get]
+func (s *Server) GetAttachmentPolicy(c *gin.Context) {
+	policy, err := s.attachmentPolicy(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch attachment policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetAttachmentPolicy replaces the caller's tenant upload limits (requires tenant.manage)
+// @Summary Set attachment policy
+// @Description Sets the maximum file size (up to 100 MiB) and the accepted MIME types for uploads in the caller's tenant
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param policy body AttachmentPolicyRequest true "Upload limits"
+// @Success 200 {object} models.AttachmentPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /attachment-policy [put]
+func (s *Server) SetAttachmentPolicy(c *gin.Context) {
+	var req AttachmentPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.MaxBytes > services.MaxAttachmentBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("max_bytes may not exceed %d", services.MaxAttachmentBytes)})
+		return
+	}
+	types := make([]string, 0, len(req.AllowedTypes))
+	for _, t := range uniqueStrings(req.AllowedTypes) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if major, minor, ok := strings.Cut(t, "/"); !ok || major == "" || minor == "" || strings.Contains(t, ",") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid MIME type: " + t})
+			return
+		}
+		types = append(types, t)
+	}
+	policy := models.AttachmentPolicy{
+		TenantID:     c.GetString("tenant_id"),
+		MaxBytes:     req.MaxBytes,
+		AllowedTypes: strings.Join(types, ","),
+	}
+	if err := s.Attachments.SavePolicy(c.Request.Context(), &policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save attachment policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// attachmentPolicy returns the upload limits of tenantID, or the defaults if
+// the tenant has not configured any
+func (s *Server) attachmentPolicy(ctx context.Context, tenantID string) (*models.AttachmentPolicy, error) {
+	policy, err := s.Attachments.Policy(ctx, tenantID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &models.AttachmentPolicy{
+			TenantID:     tenantID,
+			MaxBytes:     services.DefaultAttachmentMaxBytes,
+			AllowedTypes: services.DefaultAttachmentTypes,
+		}, nil
+	}
+	return policy, err
+}
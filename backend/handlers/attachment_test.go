@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// upload posts content as a multipart file to /attachments
+func (f *fixture) upload(t *testing.T, as models.User, streamID, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	tokens, err := services.IssueTokens(context.Background(), as)
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("stream_id", streamID)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/attachments", &buf)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSendMessageWithAttachment(t *testing.T) {
+	f := newFixture(t)
+	w := f.upload(t, f.memberB, f.chanB.StreamID, "shot.png", pngHeader)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d, body %s", w.Code, w.Body)
+	}
+	var att models.Attachment
+	if err := json.Unmarshal(w.Body.Bytes(), &att); err != nil {
+		t.Fatal(err)
+	}
+	if att.ContentType != "image/png" || att.Size != int64(len(pngHeader)) {
+		t.Errorf("attachment = %+v", att)
+	}
+	// Only the uploader can send their attachment
+	w = f.do(t, f.adminB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "attachment_ids": []string{att.ID}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("send someone else's attachment status = %d, want 400", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "attachment_ids": []string{att.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send status = %d, body %s", w.Code, w.Body)
+	}
+
+	w = f.do(t, f.guestB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	var page MessagesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 || len(page.Messages[0].Attachments) != 1 {
+		t.Fatalf("messages = %+v", page.Messages)
+	}
+	url := page.Messages[0].Attachments[0].URL
+	w = f.do(t, f.adminA, http.MethodGet, url, nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), pngHeader) {
+		t.Errorf("download status = %d, body %q", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("download content type = %q", ct)
+	}
+	tampered := strings.Replace(url, "expires=", "expires=9", 1)
+	if w := f.do(t, f.memberB, http.MethodGet, tampered, nil); w.Code != http.StatusForbidden {
+		t.Errorf("tampered download status = %d, want 403", w.Code)
+	}
+}
+
+func TestUploadsFollowTenantPolicy(t *testing.T) {
+	f := newFixture(t)
+	if w := f.upload(t, f.memberB, f.chanB.StreamID, "run.sh", []byte("\x7fELF\x02\x01\x01")); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("binary upload status = %d, want 415", w.Code)
+	}
+	policy := gin.H{"max_bytes": 8, "allowed_types": []string{"image/*"}}
+	if w := f.do(t, f.memberB, http.MethodPut, "/attachment-policy", policy); w.Code != http.StatusForbidden {
+		t.Errorf("member set policy status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodPut, "/attachment-policy", policy); w.Code != http.StatusOK {
+		t.Fatalf("set policy status = %d, body %s", w.Code, w.Body)
+	}
+	if w := f.upload(t, f.memberB, f.chanB.StreamID, "shot.png", pngHeader); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload status = %d, want 413", w.Code)
+	}
+	// Tenant A keeps the defaults
+	chanA := f.createChannel(t, f.adminA, "random")
+	if w := f.upload(t, f.adminA, chanA.StreamID, "shot.png", pngHeader); w.Code != http.StatusCreated {
+		t.Errorf("tenant A upload status = %d, body %s", w.Code, w.Body)
+	}
+}
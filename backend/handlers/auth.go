@@ -2,64 +2,100 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"os"
 	"time"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/Tabintel/multi-tenant-chat/backend/db"
 	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
 	"github.com/Tabintel/multi-tenant-chat/backend/services"
-	"strings"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// TenantID picks the organization to sign in to; defaults to the first one joined
+	TenantID string `json:"tenant_id"`
 }
 
 type LoginResponse struct {
-	Token   string `json:"token"`
-	Message string `json:"message"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Message      string `json:"message"`
+	// TenantID is the organization the tokens are scoped to
+	TenantID string `json:"tenant_id"`
+	// Tenants lists every organization the user can switch to
+	Tenants []repository.UserTenant `json:"tenants"`
+}
+
+// SwitchTenantRequest picks another organization of the signed-in user
+type SwitchTenantRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
 }
 
+// RegisterRequest creates a new organization whose first user is its ADMIN.
+// To join an existing organization, use an invite (POST /auth/accept-invite).
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
-	Role     string `json:"role" binding:"required,oneof=ADMIN MODERATOR MEMBER GUEST"`
 	OrgName  string `json:"org_name" binding:"required"`
 }
 
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 type RegisterResponse struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
-	Token string `json:"token,omitempty"`
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All revokes every session of the user, not just the current one
+	All bool `json:"all"`
 }
 
 // Login authenticates a user and returns a JWT token
 // @Summary Login
-// @Description Authenticates a user and returns a JWT token
+// @Description Authenticates a user and returns a JWT token scoped to one of their organizations (tenant_id, or the first one joined), with the list of organizations they can switch to
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param login body LoginRequest true "Login credentials"
 // @Success 200 {object} LoginResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
-func Login(c *gin.Context) {
+func (s *Server) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 	// Validate user (fetch from DB, check password)
-	var user models.User
-	if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	user, err := s.Users.FindByEmail(c.Request.Context(), req.Email)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -67,55 +103,119 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
-	// Issue JWT token with all required claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"email": user.Email,
-		"role": user.Role,
-		"tenant_id": user.TenantID,
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
-	})
-	tokenString, err := token.SignedString(jwtSecret)
+	// Deleted users, and users of deleted tenants, are told so only after
+	// proving their password
+	if user.DeletedAt.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+	tenants, err := s.Users.Tenants(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organizations"})
+		return
+	}
+	if len(tenants) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+	tenantID := req.TenantID
+	if tenantID == "" {
+		tenantID = tenants[0].ID
+	}
+	respondTenantTokens(c, user, tenantID, tenants, "Successfully logged in to the multi-tenant chat")
+}
+
+// SwitchTenant issues tokens for another organization of the caller
+// @Summary Switch organization
+// @Description Issues new tokens scoped to another organization the caller is a member of, with their role there
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param switch body SwitchTenantRequest true "Organization to switch to"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /auth/switch-tenant [post]
+func (s *Server) SwitchTenant(c *gin.Context) {
+	var req SwitchTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	user, err := s.Users.Get(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user"})
+		return
+	}
+	tenants, err := s.Users.Tenants(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch organizations"})
+		return
+	}
+	respondTenantTokens(c, user, req.TenantID, tenants, "Switched organization")
+}
+
+// respondTenantTokens writes a LoginResponse with tokens for user in tenantID
+func respondTenantTokens(c *gin.Context, user *models.User, tenantID string, tenants []repository.UserTenant, message string) {
+	tokens, err := services.IssueTenantTokens(c.Request.Context(), user, tenantID)
+	if errors.Is(err, services.ErrUserDeactivated) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this organization"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
 		return
 	}
 	c.JSON(http.StatusOK, LoginResponse{
-		Token:   tokenString,
-		Message: "Successfully logged in to the multi-tenant chat",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		Message:      message,
+		TenantID:     user.TenantID,
+		Tenants:      tenants,
 	})
 }
 
 // Register handles user registration (sign up)
-// @Summary Register a new user
-// @Description Register a new user (admin, moderator, or user)
+// @Summary Register a new organization
+// @Description Creates a new organization (tenant) and its first user, who becomes its ADMIN. Other users, and existing accounts, join through invites.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param register body RegisterRequest true "Registration info"
 // @Success 201 {object} RegisterResponse
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /auth/register [post]
-func Register(c *gin.Context) {
+func (s *Server) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
 
-	role := strings.ToUpper(req.Role)
-	if role != "ADMIN" && role != "MODERATOR" && role != "MEMBER" && role != "GUEST" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: must be ADMIN, MODERATOR, MEMBER, or GUEST"})
+	ctx := c.Request.Context()
+	// Names of deleted tenants stay reserved so they can be restored
+	taken, err := s.Tenants.NameTaken(ctx, req.OrgName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create tenant"})
 		return
 	}
-
-	var tenant models.Tenant
-	if req.OrgName != "" {
-		tenant = models.Tenant{Name: req.OrgName}
-		if err := db.DB.Create(&tenant).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create tenant"})
-			return
-		}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization already exists: ask one of its admins for an invite"})
+		return
+	}
+	// Existing accounts join further organizations through invites
+	taken, err = s.Users.EmailTaken(ctx, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
 	}
 
 	hash, err := services.HashPassword(req.Password)
@@ -127,32 +227,175 @@ func Register(c *gin.Context) {
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: string(hash),
-		Role:     models.Role(role),
-		TenantID: tenant.ID,
+		Role:     models.RoleAdmin,
 	}
-	if err := db.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+	// The tenant only exists together with its first admin
+	err = s.Transaction(ctx, func(repos repository.Repos) error {
+		tenant := models.Tenant{Name: req.OrgName}
+		if err := repos.Tenants.Create(ctx, &tenant); err != nil {
+			return err
+		}
+		user.TenantID = tenant.ID
+		return repos.Users.Create(ctx, &user)
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization or email already registered"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register"})
 		return
 	}
 
-	// Issue JWT token on successful registration
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"email": user.Email,
-		"role": user.Role,
-		"tenant_id": user.TenantID,
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
-	})
-	tokenString, err := token.SignedString(jwtSecret)
+	// Issue tokens on successful registration
+	tokens, err := services.IssueTokens(ctx, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, RegisterResponse{
-		ID:    user.ID,
-		Email: user.Email,
-		Role:  string(user.Role),
-		Token: tokenString,
+		ID:           user.ID,
+		Email:        user.Email,
+		Role:         string(user.Role),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// Refresh exchanges a refresh token for a new token pair
+// @Summary Refresh tokens
+// @Description Rotates a refresh token: the presented token is revoked and a new access/refresh pair is returned. Reusing a rotated token revokes all of the user's sessions.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param refresh body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (s *Server) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	tokens, err := services.RefreshTokens(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if errors.Is(err, services.ErrUserDeactivated) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// Logout revokes the current access token and the given refresh token
+// @Summary Logout
+// @Description Revokes the current access token and refresh token, or every session of the user when all is true
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param logout body LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} map[string]bool
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /auth/logout [post]
+func (s *Server) Logout(c *gin.Context) {
+	var req LogoutRequest
+	// The body is optional; without it only the access token is revoked
+	_ = c.ShouldBindJSON(&req)
+	ctx := c.Request.Context()
+	if req.All {
+		if err := services.RevokeUserTokens(ctx, c.GetString("user_id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"logged_out": true})
+		return
+	}
+	if req.RefreshToken != "" {
+		if err := services.RevokeRefreshToken(ctx, c.GetString("user_id"), req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+			return
+		}
+	}
+	exp, _ := c.Get("token_exp")
+	expiresAt, ok := exp.(time.Time)
+	if !ok {
+		expiresAt = time.Now().Add(services.AccessTokenTTL())
+	}
+	if err := services.RevokeAccessToken(ctx, c.GetString("jti"), expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logged_out": true})
+}
+
+// AcceptInvite joins the inviting organization, creating an account if needed
+// @Summary Accept invite
+// @Description Adds the invited email to the invite's tenant with the invited role, then logs them in there. A new account is created unless one exists for the email, in which case its password must be given and name is ignored.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param accept body AcceptInviteRequest true "Invite token and account info"
+// @Success 201 {object} RegisterResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/accept-invite [post]
+func (s *Server) AcceptInvite(c *gin.Context) {
+	var req AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := services.AcceptInvite(ctx, req.Token, req.Name, req.Password)
+	if errors.Is(err, services.ErrInvalidInvite) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite"})
+		return
+	}
+	if errors.Is(err, services.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this organization"})
+		return
+	}
+	if errors.Is(err, services.ErrWrongPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "An account with this email exists; enter its password"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not accept invite"})
+		return
+	}
+	if err := services.Chat().UpsertUser(ctx, *user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stream user creation failed"})
+		return
+	}
+	tokens, err := services.IssueTokens(ctx, *user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
+		return
+	}
+	c.JSON(http.StatusCreated, RegisterResponse{
+		ID:           user.ID,
+		Email:        user.Email,
+		Role:         string(user.Role),
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	})
 }
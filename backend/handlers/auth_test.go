@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// issue signs user in and returns their tokens
+func issue(t *testing.T, user models.User) *services.TokenPair {
+	t.Helper()
+	tokens, err := services.IssueTokens(context.Background(), user)
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+	return tokens
+}
+
+// refresh presents refreshToken and returns the status and the new tokens
+func (f *fixture) refresh(t *testing.T, refreshToken string) (int, TokenResponse) {
+	t.Helper()
+	w := f.send(t, "", http.MethodPost, "/auth/refresh", gin.H{"refresh_token": refreshToken})
+	var resp TokenResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	f := newFixture(t)
+	tokens := issue(t, f.memberB)
+	code, rotated := f.refresh(t, tokens.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh status = %d", code)
+	}
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if w := f.send(t, rotated.Token, http.MethodGet, "/channels", nil); w.Code != http.StatusOK {
+		t.Errorf("new access token status = %d", w.Code)
+	}
+	if code, _ := f.refresh(t, rotated.RefreshToken); code != http.StatusOK {
+		t.Errorf("second rotation status = %d", code)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSessions(t *testing.T) {
+	f := newFixture(t)
+	tokens := issue(t, f.memberB)
+	_, rotated := f.refresh(t, tokens.RefreshToken)
+
+	// Replaying the rotated token looks like theft: every session ends
+	if code, _ := f.refresh(t, tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token status = %d, want 401", code)
+	}
+	if code, _ := f.refresh(t, rotated.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh token of the same family status = %d, want 401", code)
+	}
+	if w := f.send(t, rotated.Token, http.MethodGet, "/channels", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token after reuse status = %d, want 401", w.Code)
+	}
+}
+
+func TestConcurrentRefreshSucceedsOnce(t *testing.T) {
+	f := newFixture(t)
+	tokens := issue(t, f.memberB)
+	codes := make([]int, 4)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = f.send(t, "", http.MethodPost, "/auth/refresh", gin.H{"refresh_token": tokens.RefreshToken}).Code
+		}(i)
+	}
+	wg.Wait()
+	ok := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Errorf("statuses = %v, want exactly one 200", codes)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	f := newFixture(t)
+	tokens := issue(t, f.memberB)
+	other := issue(t, f.memberB)
+	w := f.send(t, tokens.AccessToken, http.MethodPost, "/auth/logout", gin.H{"refresh_token": tokens.RefreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout status = %d, body %s", w.Code, w.Body)
+	}
+	// The access token is denylisted until it expires
+	if w := f.send(t, tokens.AccessToken, http.MethodGet, "/channels", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout status = %d, want 401", w.Code)
+	}
+	// Other sessions are left alone
+	if w := f.send(t, other.AccessToken, http.MethodGet, "/channels", nil); w.Code != http.StatusOK {
+		t.Errorf("other session status = %d, want 200", w.Code)
+	}
+	if code, _ := f.refresh(t, tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh token after logout status = %d, want 401", code)
+	}
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	f := newFixture(t)
+	tokens := issue(t, f.memberB)
+	other := issue(t, f.memberB)
+	if w := f.send(t, tokens.AccessToken, http.MethodPost, "/auth/logout", gin.H{"all": true}); w.Code != http.StatusOK {
+		t.Fatalf("logout status = %d, body %s", w.Code, w.Body)
+	}
+	if w := f.send(t, other.AccessToken, http.MethodGet, "/channels", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("other access token status = %d, want 401", w.Code)
+	}
+	if code, _ := f.refresh(t, other.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("other refresh token status = %d, want 401", code)
+	}
+}
+
+func TestOnlyAccessTokensAreAccepted(t *testing.T) {
+	f := newFixture(t)
+	chatToken, err := services.NewLocalChat(db.DB).CreateToken(f.memberB.ID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := f.send(t, chatToken, http.MethodGet, "/channels", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("chat token status = %d, want 401", w.Code)
+	}
+
+	// Access tokens must say so and carry a jti, which is what the denylist keys on
+	for name, claims := range map[string]jwt.MapClaims{
+		"without typ": {"jti": "some-id"},
+		"without jti": {"typ": "access"},
+	} {
+		claims["user_id"] = f.memberB.ID
+		claims["tenant_id"] = f.memberB.TenantID
+		claims["role"] = f.memberB.Role
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := f.send(t, token, http.MethodGet, "/channels", nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %s status = %d, want 401", name, w.Code)
+		}
+	}
+}
+
+func TestRegisterWithExistingEmailLeavesNoTenant(t *testing.T) {
+	f := newFixture(t)
+	body := gin.H{"org_name": "Tenant C", "name": "Member B", "email": f.memberB.Email, "password": "secret123"}
+	if w := f.send(t, "", http.MethodPost, "/auth/register", body); w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	var count int64
+	db.DB.Unscoped().Model(&models.Tenant{}).Where("name = ?", "Tenant C").Count(&count)
+	if count != 0 {
+		t.Error("the rejected registration left its tenant behind")
+	}
+
+	body["email"] = "new@c.test"
+	if w := f.send(t, "", http.MethodPost, "/auth/register", body); w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+}
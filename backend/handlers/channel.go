@@ -2,43 +2,51 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 	"github.com/gin-gonic/gin"
 	"github.com/Tabintel/multi-tenant-chat/backend/db"
 	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
 	"github.com/Tabintel/multi-tenant-chat/backend/services"
 )
 
-// CreateChannel creates a new channel (Admin/Moderator only)
+// CreateChannelRequest is the payload for creating a channel; the caller becomes its owner
+type CreateChannelRequest struct {
+	Name              string             `json:"name" binding:"required"`
+	Description       string             `json:"description"`
+	Type              models.ChannelType `json:"type"` // public (default), private or announcement
+	AllowGuestPosting bool               `json:"allow_guest_posting"`
+}
+
+// CreateChannel creates a new channel (requires channel.create)
 // @Summary Create a channel
-// @Description Creates a new chat channel for the tenant and Stream
+// @Description Creates a new public, private or announcement chat channel for the tenant and Stream; the creator becomes its owner
 // @Tags channels
 // @Accept json
 // @Produce json
-// @Param channel body models.Channel true "Channel info"
+// @Param channel body CreateChannelRequest true "Channel info"
 // @Success 201 {object} models.Channel
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /channels [post]
-func CreateChannel(c *gin.Context) {
-	var req struct {
-		Name        string `json:"name" binding:"required"`
-		Description string `json:"description"`
-		CreatedBy   string `json:"createdBy"`
-	}
+func (s *Server) CreateChannel(c *gin.Context) {
+	var req CreateChannelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	userRole, _ := c.Get("user_role")
-	if userRole != string(models.RoleAdmin) && userRole != string(models.RoleModerator) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	if req.Type == "" {
+		req.Type = models.ChannelPublic
+	}
+	if !req.Type.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel type"})
 		return
 	}
 	tenantID, _ := c.Get("tenant_id")
 	userID, _ := c.Get("user_id")
 	// Create channel in Stream
-	streamChannelID, err := services.CreateStreamChannel(models.Channel{
+	streamChannelID, err := services.Chat().CreateChannel(c.Request.Context(), models.Channel{
 		Name:        req.Name,
 		Description: req.Description,
 		TenantID:    tenantID.(string),
@@ -50,34 +58,267 @@ func CreateChannel(c *gin.Context) {
 	}
 	// Save channel to DB
 	channel := models.Channel{
-		StreamID:    streamChannelID,
-		Name:        req.Name,
-		Description: req.Description,
-		TenantID:    tenantID.(string),
-		CreatedBy:   userID.(string),
+		StreamID:          streamChannelID,
+		Name:              req.Name,
+		Description:       req.Description,
+		TenantID:          tenantID.(string),
+		CreatedBy:         userID.(string),
+		Type:              req.Type,
+		AllowGuestPosting: req.AllowGuestPosting,
 	}
-	if err := db.DB.Create(&channel).Error; err != nil {
+	if err := s.Channels.Create(c.Request.Context(), &channel); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create channel in DB"})
 		return
 	}
+	// The provider already added the creator; this records them as owner
+	err = services.AddChannelMembers(c.Request.Context(), channel, []string{channel.CreatedBy}, models.ChannelRoleOwner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add channel owner"})
+		return
+	}
 	c.JSON(http.StatusCreated, channel)
 }
 
-// ListChannels lists all channels for a tenant
+// channelListSpec is the sorting and search supported by ListChannels
+var channelListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"name":       {Column: "name"},
+		"created_at": {Column: "created_at", Time: true},
+	},
+	DefaultSort:   "name",
+	SearchColumns: []string{"name", "description"},
+}
+
+// ListChannels lists the channels of a tenant visible to the caller
 // @Summary List channels
-// @Description Lists all chat channels for the tenant
+// @Description Lists, a page at a time, the public and announcement channels of the tenant and the private channels the caller is a member of. Archived channels are hidden unless include_archived=true.
 // @Tags channels
 // @Produce json
-// @Success 200 {array} models.Channel
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name or created_at; prefix with - for descending"
+// @Param q query string false "Search by name or description"
+// @Param type query string false "Only channels of this type (public, private, announcement)"
+// @Param include_archived query bool false "Include archived channels"
+// @Success 200 {object} db.Page[models.Channel]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /channels [get]
-func ListChannels(c *gin.Context) {
-	tenantID, _ := c.Get("tenant_id")
-	var channels []models.Channel
-	if err := db.DB.Where("tenant_id = ?", tenantID).Find(&channels).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channels"})
+func (s *Server) ListChannels(c *gin.Context) {
+	// Direct conversations are listed by ListConversations
+	filter := repository.ChannelFilter{
+		VisibleTo:       c.GetString("user_id"),
+		Type:            models.ChannelType(c.Query("type")),
+		IncludeArchived: c.Query("include_archived") == "true",
+	}
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.Channel], error) {
+		return s.Channels.List(c.Request.Context(), c.GetString("tenant_id"), filter, channelListSpec, opts)
+	}, "channels")
+}
+
+// JoinChannel adds the caller to a public or announcement channel
+// @Summary Join a channel
+// @Description Adds the caller to a public or announcement channel of their tenant; private channels are invite only
+// @Tags channels
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {object} map[string]bool
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/join [post]
+func (s *Server) JoinChannel(c *gin.Context) {
+	channel, ok := s.channelByID(c)
+	if !ok {
+		return
+	}
+	if channel.Type.MembersOnly() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This channel is invite only"})
+		return
+	}
+	if channel.ArchivedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Channel is archived"})
+		return
+	}
+	userID := c.GetString("user_id")
+	if err := services.AddChannelMembers(c.Request.Context(), *channel, []string{userID}, models.ChannelRoleMember); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not join channel"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"joined": true})
+}
+
+// UpdateChannelRequest is the payload for renaming or describing a channel;
+// omitted fields are left unchanged
+type UpdateChannelRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// requireChannelManager checks that the caller holds channel.manage or a
+// managing channel role (only owner if ownerOnly). On failure it writes the
+// error response and returns false.
+func requireChannelManager(c *gin.Context, channel *models.Channel, ownerOnly bool) bool {
+	role, manage, err := channelAccess(c, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return false
+	}
+	allowed := manage || role == models.ChannelRoleOwner || (!ownerOnly && role.CanManage())
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to manage this channel"})
+		return false
+	}
+	return true
+}
+
+// UpdateChannel renames a channel or changes its description
+// @Summary Update a channel
+// @Description Changes the name and/or description of a channel of the caller's tenant and syncs it to the chat provider. Requires channel.manage or being a channel owner/moderator.
+// @Tags channels
+// @Accept json
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Param channel body UpdateChannelRequest true "Fields to change"
+// @Success 200 {object} models.Channel
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id} [put]
+func (s *Server) UpdateChannel(c *gin.Context) {
+	var req UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel name cannot be empty"})
+		return
+	}
+	channel, ok := s.channelByID(c)
+	if !ok || !requireChannelManager(c, channel, false) {
+		return
+	}
+	if req.Name != nil {
+		channel.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		channel.Description = *req.Description
+	}
+	if err := services.UpdateChannel(c.Request.Context(), channel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update channel"})
+		return
+	}
+	c.JSON(http.StatusOK, channel)
+}
+
+// ArchiveChannel makes a channel read-only and hides it from ListChannels
+// @Summary Archive a channel
+// @Description Archives a channel of the caller's tenant: it becomes read-only, is frozen in the chat provider and is hidden from the channel list by default. Requires channel.manage or being the channel owner.
+// @Tags channels
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {object} models.Channel
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/archive [post]
+func (s *Server) ArchiveChannel(c *gin.Context) {
+	channel, ok := s.channelByID(c)
+	if !ok || !requireChannelManager(c, channel, true) {
+		return
+	}
+	if channel.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Channel is already archived"})
+		return
+	}
+	if err := services.ArchiveChannel(c.Request.Context(), channel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not archive channel"})
+		return
+	}
+	c.JSON(http.StatusOK, channel)
+}
+
+// DeleteChannel soft-deletes a channel
+// @Summary Delete a channel
+// @Description Soft-deletes a channel of the caller's tenant and hides it in the chat provider; it can be restored during the grace period. Requires channel.manage or being the channel owner.
+// @Tags channels
+// @Param id path string true "Channel ID"
+// @Success 200 {object} map[string]bool
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id} [delete]
+func (s *Server) DeleteChannel(c *gin.Context) {
+	channel, ok := s.channelByID(c)
+	if !ok || !requireChannelManager(c, channel, true) {
+		return
+	}
+	if err := services.DeleteChannel(c.Request.Context(), channel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete channel"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// deletedChannelListSpec is the sorting and search supported by ListDeletedChannels
+var deletedChannelListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"name":       {Column: "name"},
+		"deleted_at": {Column: "deleted_at", Time: true},
+	},
+	DefaultSort:   "-deleted_at",
+	SearchColumns: []string{"name", "description"},
+}
+
+// ListDeletedChannels lists the deleted channels that can still be restored (requires channel.manage)
+// @Summary List deleted channels
+// @Description Lists the deleted named channels of the caller's tenant that are still within the grace period. Direct conversations are not listed.
+// @Tags channels
+// @Produce json
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "deleted_at or name; prefix with - for descending (default -deleted_at)"
+// @Param q query string false "Search by name or description"
+// @Success 200 {object} db.Page[models.Channel]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/deleted [get]
+func (s *Server) ListDeletedChannels(c *gin.Context) {
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.Channel], error) {
+		return s.Channels.ListDeleted(c.Request.Context(), c.GetString("tenant_id"), services.RestorableSince(), deletedChannelListSpec, opts)
+	}, "deleted channels")
+}
+
+// RestoreChannel restores a deleted channel (requires channel.manage)
+// @Summary Restore a channel
+// @Description Restores a deleted named channel of the caller's tenant within the grace period, with its members and messages
+// @Tags channels
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {object} models.Channel
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/restore [post]
+func (s *Server) RestoreChannel(c *gin.Context) {
+	channel, err := s.Channels.GetDeleted(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted channel not found"})
+		return
+	}
+	if err := services.RestoreChannel(c.Request.Context(), channel); err != nil {
+		respondRestoreError(c, err, "channel")
 		return
 	}
-	c.JSON(http.StatusOK, channels)
+	c.JSON(http.StatusOK, channel)
 }
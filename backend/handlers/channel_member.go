@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// AddChannelMembersRequest is the payload for adding users to a channel
+type AddChannelMembersRequest struct {
+	UserIDs []string           `json:"user_ids" binding:"required,min=1"`
+	Role    models.ChannelRole `json:"role"` // owner, moderator or member (default)
+}
+
+// RemoveChannelMembersRequest is the payload for removing users from a channel
+type RemoveChannelMembersRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1"`
+}
+
+// channelByID loads a channel of the caller's tenant by its ID.
+// On failure it writes the error response and returns false.
+func (s *Server) channelByID(c *gin.Context) (*models.Channel, bool) {
+	channel, err := s.Channels.Get(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channel"})
+		}
+		return nil, false
+	}
+	return channel, true
+}
+
+// checkMutableMembers rejects membership changes of direct conversations,
+// whose participants identify them. It writes a 400 response and returns false.
+func checkMutableMembers(c *gin.Context, channel *models.Channel) bool {
+	if channel.Type == models.ChannelDirect {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Participants of a direct conversation cannot be changed"})
+		return false
+	}
+	return true
+}
+
+// channelAccess returns the caller's role in the channel ("" if not a member)
+// and whether they hold the tenant-wide channel.manage permission
+func channelAccess(c *gin.Context, channel *models.Channel) (models.ChannelRole, bool, error) {
+	role, err := services.ChannelMemberRole(c.Request.Context(), channel.ID, c.GetString("user_id"))
+	if err != nil {
+		return "", false, err
+	}
+	manage, err := hasPermission(c, models.PermChannelManage)
+	return role, manage, err
+}
+
+// AddChannelMembers adds users of the caller's tenant to a channel
+// @Summary Add channel members
+// @Description Adds users of the caller's tenant to a channel with a channel role. Requires channel.manage or being a channel owner/moderator; only owners and channel.manage may add owners.
+// @Tags channels
+// @Accept json
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Param members body AddChannelMembersRequest true "Users to add"
+// @Success 200 {array} services.ChannelMemberInfo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/members [post]
+func (s *Server) AddChannelMembers(c *gin.Context) {
+	var req AddChannelMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.ChannelRoleMember
+	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel role"})
+		return
+	}
+	channel, ok := s.channelByID(c)
+	if !ok || !checkMutableMembers(c, channel) {
+		return
+	}
+	callerRole, manage, err := channelAccess(c, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return
+	}
+	if !manage && !callerRole.CanManage() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to manage this channel"})
+		return
+	}
+	if req.Role == models.ChannelRoleOwner && !manage && callerRole != models.ChannelRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only channel owners can add owners"})
+		return
+	}
+	userIDs := uniqueStrings(req.UserIDs)
+	// Users of other tenants are indistinguishable from unknown users
+	found, err := s.Users.CountMembers(c.Request.Context(), c.GetString("tenant_id"), userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
+		return
+	}
+	if found != len(userIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Users must belong to the channel's tenant"})
+		return
+	}
+	ctx := c.Request.Context()
+	if err := services.AddChannelMembers(ctx, *channel, userIDs, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add channel members"})
+		return
+	}
+	respondChannelMembers(c, channel)
+}
+
+// RemoveChannelMembers removes users from a channel
+// @Summary Remove channel members
+// @Description Removes users from a channel. Requires channel.manage or being a channel owner/moderator; any member may remove themselves.
+// @Tags channels
+// @Accept json
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Param members body RemoveChannelMembersRequest true "Users to remove"
+// @Success 200 {array} services.ChannelMemberInfo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/members [delete]
+func (s *Server) RemoveChannelMembers(c *gin.Context) {
+	var req RemoveChannelMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	channel, ok := s.channelByID(c)
+	if !ok || !checkMutableMembers(c, channel) {
+		return
+	}
+	userIDs := uniqueStrings(req.UserIDs)
+	leaving := len(userIDs) == 1 && userIDs[0] == c.GetString("user_id")
+	if !leaving {
+		callerRole, manage, err := channelAccess(c, channel)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+			return
+		}
+		if !manage && !callerRole.CanManage() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to manage this channel"})
+			return
+		}
+	}
+	if err := services.RemoveChannelMembers(c.Request.Context(), *channel, userIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not remove channel members"})
+		return
+	}
+	respondChannelMembers(c, channel)
+}
+
+// ListChannelMembers lists the members of a channel
+// @Summary List channel members
+// @Description Lists the members of a channel with their channel role and join time. Requires being a member or channel.manage.
+// @Tags channels
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {array} services.ChannelMemberInfo
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /channels/{id}/members [get]
+func (s *Server) ListChannelMembers(c *gin.Context) {
+	channel, ok := s.channelByID(c)
+	if !ok {
+		return
+	}
+	callerRole, manage, err := channelAccess(c, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return
+	}
+	if callerRole == "" && !manage {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this channel"})
+		return
+	}
+	respondChannelMembers(c, channel)
+}
+
+func respondChannelMembers(c *gin.Context, channel *models.Channel) {
+	members, err := services.ListChannelMembers(c.Request.Context(), channel.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channel members"})
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddChannelMemberSyncsProvider(t *testing.T) {
+	f := newFixture(t)
+	other := models.User{Name: "Other B", Email: "other@b.test", Password: "x", Role: models.RoleMember, TenantID: f.tenantB.ID}
+	mustCreate(t, &other)
+	w := f.do(t, f.adminB, http.MethodPost, "/channels/"+f.chanB.ID+"/members", gin.H{"user_ids": []string{other.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var members []services.ChannelMemberInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &members); err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 4 {
+		t.Errorf("got %d members, want 4", len(members))
+	}
+	ids, err := services.Chat().ListMembers(context.Background(), f.chanB.StreamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, id := range ids {
+		found = found || id == other.ID
+	}
+	if !found {
+		t.Errorf("new member was not added to the chat provider")
+	}
+}
+
+func TestAddChannelMemberFromOtherTenantIsRejected(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPost, "/channels/"+f.chanB.ID+"/members", gin.H{"user_ids": []string{f.adminA.ID}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if member, _ := services.IsChannelMember(context.Background(), f.chanB.ID, f.adminA.ID); member {
+		t.Errorf("user of another tenant was added")
+	}
+}
+
+func TestChannelMembersCrossTenantIsNotFound(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodGet, "/channels/"+f.chanB.ID+"/members", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestMemberCannotRemoveOthersButCanLeave(t *testing.T) {
+	f := newFixture(t)
+	path := "/channels/" + f.chanB.ID + "/members"
+	w := f.do(t, f.memberB, http.MethodDelete, path, gin.H{"user_ids": []string{f.guestB.ID}})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("remove other: status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodDelete, path, gin.H{"user_ids": []string{f.memberB.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("leave: status = %d, body %s", w.Code, w.Body)
+	}
+	if member, _ := services.IsChannelMember(context.Background(), f.chanB.ID, f.memberB.ID); member {
+		t.Errorf("member is still in the channel after leaving")
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateChannelRenames(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPut, "/channels/"+f.chanB.ID, gin.H{"name": "general-chat"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var chatChannel models.ChatChannel
+	if err := db.DB.First(&chatChannel, "id = ?", f.chanB.StreamID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if chatChannel.Name != "general-chat" {
+		t.Errorf("provider channel name = %q, want the new name", chatChannel.Name)
+	}
+}
+
+func TestUpdateChannelRequiresManager(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPut, "/channels/"+f.chanB.ID, gin.H{"name": "mine"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.adminA, http.MethodPut, "/channels/"+f.chanB.ID, gin.H{"name": "mine"})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant status = %d, want 404", w.Code)
+	}
+}
+
+func TestArchivedChannelIsReadOnlyAndHidden(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPost, "/channels/"+f.chanB.ID+"/archive", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("archive status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "hi"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("send status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("read status = %d, body %s", w.Code, w.Body)
+	}
+	if n := listedChannels(t, f, f.memberB, "/channels"); n != 0 {
+		t.Errorf("archived channel listed by default")
+	}
+	if n := listedChannels(t, f, f.memberB, "/channels?include_archived=true"); n != 1 {
+		t.Errorf("include_archived listed %d channels, want 1", n)
+	}
+}
+
+func TestDeleteChannelIsSoft(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodDelete, "/channels/"+f.chanB.ID, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("member status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.adminB, http.MethodDelete, "/channels/"+f.chanB.ID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := listedChannels(t, f, f.adminB, "/channels?include_archived=true"); n != 0 {
+		t.Errorf("deleted channel is still listed")
+	}
+	var count int64
+	db.DB.Unscoped().Model(&models.Channel{}).Where("id = ?", f.chanB.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("channel row was hard-deleted")
+	}
+}
+
+func TestRestoreDeletedChannel(t *testing.T) {
+	f := newFixture(t)
+	if w := f.do(t, f.adminB, http.MethodDelete, "/channels/"+f.chanB.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := listedChannels(t, f, f.adminB, "/channels/deleted"); n != 1 {
+		t.Fatalf("deleted channels = %d, want 1", n)
+	}
+	if w := f.do(t, f.memberB, http.MethodPost, "/channels/"+f.chanB.ID+"/restore", nil); w.Code != http.StatusForbidden {
+		t.Errorf("member restore status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodPost, "/channels/"+f.chanB.ID+"/restore", nil); w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, body %s", w.Code, w.Body)
+	}
+	if n := listedChannels(t, f, f.memberB, "/channels"); n != 1 {
+		t.Errorf("restored channel is not listed")
+	}
+	w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "back again"})
+	if w.Code != http.StatusOK {
+		t.Errorf("send to restored channel status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func listedChannels(t *testing.T, f *fixture, as models.User, path string) int {
+	t.Helper()
+	w := f.do(t, as, http.MethodGet, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list status = %d, body %s", w.Code, w.Body)
+	}
+	var channels db.Page[models.Channel]
+	if err := json.Unmarshal(w.Body.Bytes(), &channels); err != nil {
+		t.Fatal(err)
+	}
+	return len(channels.Items)
+}
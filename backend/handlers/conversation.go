@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// CreateConversationRequest is the payload for starting a DM or group DM
+type CreateConversationRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1"` // other participants; the caller is added automatically
+}
+
+// Conversation is a direct conversation with its participants.
+// Messages are sent and read with /messages using Channel.StreamID.
+type Conversation struct {
+	Channel      models.Channel               `json:"channel"`
+	Participants []services.ChannelMemberInfo `json:"participants"`
+}
+
+// CreateConversation starts (or reuses) a DM or group DM
+// @Summary Start a conversation
+// @Description Returns the direct conversation between the caller and the given users of the same tenant, creating it if it does not exist yet. The same participants always get the same conversation.
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param conversation body CreateConversationRequest true "Participants"
+// @Success 200 {object} Conversation "Existing conversation"
+// @Success 201 {object} Conversation "New conversation"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /conversations [post]
+func (s *Server) CreateConversation(c *gin.Context) {
+	var req CreateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	userID := c.GetString("user_id")
+	participants := uniqueStrings(append([]string{userID}, req.UserIDs...))
+	if len(participants) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A conversation needs at least one other participant"})
+		return
+	}
+	found, err := s.Users.CountMembers(c.Request.Context(), c.GetString("tenant_id"), participants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
+		return
+	}
+	if found != len(participants) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Participants must belong to your tenant"})
+		return
+	}
+	ctx := c.Request.Context()
+	channel, created, err := services.FindOrCreateConversation(ctx, c.GetString("tenant_id"), userID, participants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create conversation"})
+		return
+	}
+	members, err := services.ListChannelMembers(ctx, channel.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch participants"})
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, Conversation{Channel: *channel, Participants: members})
+}
+
+// ListConversations lists the caller's direct conversations
+// @Summary List my conversations
+// @Description Lists the DMs and group DMs the caller takes part in within their tenant, newest first, with their participants
+// @Tags conversations
+// @Produce json
+// @Success 200 {array} Conversation
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /conversations [get]
+func (s *Server) ListConversations(c *gin.Context) {
+	ctx := c.Request.Context()
+	channels, err := services.ListConversations(ctx, c.GetString("tenant_id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch conversations"})
+		return
+	}
+	conversations := make([]Conversation, len(channels))
+	for i, ch := range channels {
+		members, err := services.ListChannelMembers(ctx, ch.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch participants"})
+			return
+		}
+		conversations[i] = Conversation{Channel: ch, Participants: members}
+	}
+	c.JSON(http.StatusOK, conversations)
+}
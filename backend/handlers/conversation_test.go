@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+// failingMembers is a chat provider that cannot add channel members
+type failingMembers struct {
+	services.ChatProvider
+}
+
+func (failingMembers) AddMembers(context.Context, string, []string) error {
+	return errors.New("provider unavailable")
+}
+
+func TestConversationIsReusedForSameParticipants(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.adminB.ID, f.guestB.ID}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var first Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Participants) != 3 {
+		t.Errorf("got %d participants, want 3", len(first.Participants))
+	}
+
+	// Same participants, different caller and order
+	w = f.do(t, f.guestB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.memberB.ID, f.adminB.ID}})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("guest status = %d, want 403 without message.send", w.Code)
+	}
+	w = f.do(t, f.adminB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.guestB.ID, f.memberB.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var second Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &second); err != nil {
+		t.Fatal(err)
+	}
+	if second.Channel.ID != first.Channel.ID {
+		t.Errorf("same participants got a new conversation")
+	}
+}
+
+func TestConversationRejectsOtherTenantUsers(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.adminA.ID}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestConversationMessagingAndListing(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.adminB.ID}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var conv Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &conv); err != nil {
+		t.Fatal(err)
+	}
+	streamID := conv.Channel.StreamID
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": streamID, "text": "psst"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.adminB, http.MethodGet, "/messages/"+streamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("read status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.guestB, http.MethodGet, "/messages/"+streamID, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("outsider read status = %d, want 403", w.Code)
+	}
+
+	w = f.do(t, f.adminB, http.MethodGet, "/conversations", nil)
+	var convs []Conversation
+	if err := json.Unmarshal(w.Body.Bytes(), &convs); err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].Channel.ID != conv.Channel.ID {
+		t.Errorf("got %d conversations, want the new one", len(convs))
+	}
+	w = f.do(t, f.adminB, http.MethodGet, "/channels", nil)
+	var channels db.Page[models.Channel]
+	if err := json.Unmarshal(w.Body.Bytes(), &channels); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range channels.Items {
+		if ch.Type == models.ChannelDirect {
+			t.Errorf("direct conversation listed as a channel")
+		}
+	}
+}
+
+func TestConversationsAreListedPerTenant(t *testing.T) {
+	f := newFixture(t)
+	// Admin A also belongs to tenant B and has a conversation there
+	mustCreate(t, &models.TenantMember{TenantID: f.tenantB.ID, UserID: f.adminA.ID, Role: models.RoleMember})
+	inB := f.adminA
+	inB.TenantID, inB.Role = f.tenantB.ID, models.RoleMember
+	if w := f.do(t, inB, http.MethodPost, "/conversations", gin.H{"user_ids": []string{f.memberB.ID}}); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	for _, tc := range []struct {
+		as   models.User
+		want int
+	}{{inB, 1}, {f.adminA, 0}} {
+		w := f.do(t, tc.as, http.MethodGet, "/conversations", nil)
+		var convs []Conversation
+		if err := json.Unmarshal(w.Body.Bytes(), &convs); err != nil {
+			t.Fatal(err)
+		}
+		if len(convs) != tc.want {
+			t.Errorf("in tenant %s got %d conversations, want %d", tc.as.TenantID, len(convs), tc.want)
+		}
+	}
+}
+
+func TestConversationIsNotKeptWhenMembersCannotBeAdded(t *testing.T) {
+	f := newFixture(t)
+	local := services.Chat()
+	services.SetChatProvider(failingMembers{local})
+	body := gin.H{"user_ids": []string{f.adminB.ID}}
+	if w := f.do(t, f.memberB, http.MethodPost, "/conversations", body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	var left int64
+	if err := db.DB.Unscoped().Model(&models.Channel{}).Where("type = ?", models.ChannelDirect).Count(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d conversations left behind", left)
+	}
+
+	services.SetChatProvider(local)
+	if w := f.do(t, f.memberB, http.MethodPost, "/conversations", body); w.Code != http.StatusCreated {
+		t.Fatalf("retry status = %d, body %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+type fixture struct {
+	router  *gin.Engine
+	tenantA models.Tenant
+	tenantB models.Tenant
+	adminA  models.User
+	modA    models.User
+	adminB  models.User
+	memberB models.User
+	guestB  models.User
+	chanB   models.Channel
+}
+
+// newFixture builds two tenants in an in-memory database and a
+// router with the routes of cmd/main.go
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+	conn, err := db.OpenMemory()
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// The services the handlers call still use the global connection
+	db.DB = conn
+	services.SetChatProvider(services.NewLocalChat(conn))
+	services.SetBlobStore(services.NewLocalBlobStore(t.TempDir()))
+
+	f := &fixture{
+		tenantA: models.Tenant{Name: "Tenant A"},
+		tenantB: models.Tenant{Name: "Tenant B"},
+	}
+	mustCreate(t, &f.tenantA)
+	mustCreate(t, &f.tenantB)
+	f.adminA = models.User{Name: "Admin A", Email: "admin@a.test", Password: "x", Role: models.RoleAdmin, TenantID: f.tenantA.ID}
+	f.modA = models.User{Name: "Mod A", Email: "mod@a.test", Password: "x", Role: models.RoleModerator, TenantID: f.tenantA.ID}
+	f.adminB = models.User{Name: "Admin B", Email: "admin@b.test", Password: "x", Role: models.RoleAdmin, TenantID: f.tenantB.ID}
+	f.memberB = models.User{Name: "Member B", Email: "member@b.test", Password: "x", Role: models.RoleMember, TenantID: f.tenantB.ID}
+	f.guestB = models.User{Name: "Guest B", Email: "guest@b.test", Password: "x", Role: models.RoleGuest, TenantID: f.tenantB.ID}
+	for _, u := range []*models.User{&f.adminA, &f.modA, &f.adminB, &f.memberB, &f.guestB} {
+		mustCreate(t, u)
+	}
+	f.chanB = f.createChannel(t, f.adminB, "general", f.memberB, f.guestB)
+
+	r := gin.New()
+	NewServer(repository.NewGorm(conn)).Routes(r)
+	f.router = r
+	return f
+}
+
+// createChannel creates a channel in the creator's tenant, in both the
+// database and the chat provider, with the given extra members
+func (f *fixture) createChannel(t *testing.T, creator models.User, name string, members ...models.User) models.Channel {
+	t.Helper()
+	ctx := context.Background()
+	channel := models.Channel{Name: name, TenantID: creator.TenantID, CreatedBy: creator.ID}
+	streamID, err := services.Chat().CreateChannel(ctx, channel, creator.ID)
+	if err != nil {
+		t.Fatalf("create chat channel: %v", err)
+	}
+	channel.StreamID = streamID
+	mustCreate(t, &channel)
+	if err := services.AddChannelMembers(ctx, channel, []string{creator.ID}, models.ChannelRoleOwner); err != nil {
+		t.Fatalf("add owner: %v", err)
+	}
+	var ids []string
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	if err := services.AddChannelMembers(ctx, channel, ids, models.ChannelRoleMember); err != nil {
+		t.Fatalf("add members: %v", err)
+	}
+	return channel
+}
+
+func mustCreate(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := db.DB.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+func (f *fixture) do(t *testing.T, as models.User, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	tokens, err := services.IssueTokens(context.Background(), as)
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+	return f.send(t, tokens.AccessToken, method, path, body)
+}
+
+// send makes a request with the given access token, or anonymously if it is empty
+func (f *fixture) send(t *testing.T, accessToken, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func mustSave(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := db.DB.Save(value).Error; err != nil {
+		t.Fatalf("save %T: %v", value, err)
+	}
+}
+
+// asCaller sets the claims JWTAuth would set, for routes served from the
+// memory repositories, which JWTAuth cannot check tokens against
+func asCaller(user models.User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		c.Set("user_role", string(user.Role))
+		c.Set("tenant_id", user.TenantID)
+	}
+}
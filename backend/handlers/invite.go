@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// defaultInviteTTL is used when CreateInviteRequest.ExpiresInHours is not set
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// CreateInviteRequest is the payload for inviting someone to the caller's tenant
+type CreateInviteRequest struct {
+	Email          string      `json:"email" binding:"required,email"`
+	Role           models.Role `json:"role"` // built-in or custom role, MEMBER if empty
+	ExpiresInHours int         `json:"expires_in_hours" binding:"omitempty,min=1"`
+}
+
+// CreateInviteResponse is the stored invite plus the token to send to the invitee
+type CreateInviteResponse struct {
+	Invite models.Invite `json:"invite"`
+	Token  string        `json:"token"`
+}
+
+// CreateInvite invites an email address to the caller's tenant
+// @Summary Create invite
+// @Description Creates an invite to the caller's tenant with a role and expiry and returns the signed invite token for POST /auth/accept-invite
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Param invite body CreateInviteRequest true "Invite info"
+// @Success 201 {object} CreateInviteResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /invites [post]
+func (s *Server) CreateInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if !checkAssignableRole(c, req.Role) {
+		return
+	}
+	ttl := defaultInviteTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	invite := models.Invite{
+		TenantID:  c.GetString("tenant_id"),
+		Email:     req.Email,
+		Role:      req.Role,
+		InvitedBy: c.GetString("user_id"),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	token, err := services.CreateInvite(c.Request.Context(), &invite)
+	if errors.Is(err, services.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A member with this email already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create invite"})
+		return
+	}
+	c.JSON(http.StatusCreated, CreateInviteResponse{Invite: invite, Token: token})
+}
+
+// ListInvites lists the pending invites of the caller's tenant
+// @Summary List invites
+// @Description Lists the invites of the caller's tenant that have not been accepted, revoked or expired
+// @Tags invites
+// @Produce json
+// @Success 200 {array} models.Invite
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /invites [get]
+func (s *Server) ListInvites(c *gin.Context) {
+	invites, err := s.Invites.ListPending(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch invites"})
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite revokes a pending invite
+// @Summary Revoke invite
+// @Description Revokes a pending invite of the caller's tenant so its token can no longer be used
+// @Tags invites
+// @Param id path string true "Invite ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /invites/{id} [delete]
+func (s *Server) RevokeInvite(c *gin.Context) {
+	err := s.Invites.Revoke(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke invite"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": true})
+}
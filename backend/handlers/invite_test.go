@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *fixture) invite(t *testing.T, as models.User, body gin.H) CreateInviteResponse {
+	t.Helper()
+	w := f.do(t, as, http.MethodPost, "/invites", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create invite status = %d, body %s", w.Code, w.Body)
+	}
+	var resp CreateInviteResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAcceptInviteJoinsInvitingTenant(t *testing.T) {
+	f := newFixture(t)
+	inv := f.invite(t, f.adminA, gin.H{"email": "Joiner@a.test", "role": "MODERATOR"})
+
+	w := f.do(t, f.adminA, http.MethodPost, "/auth/accept-invite", gin.H{"token": inv.Token, "name": "Joiner", "password": "pw"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("accept status = %d, body %s", w.Code, w.Body)
+	}
+	var user models.User
+	if err := db.TenantUsers(context.Background(), f.tenantA.ID, false).First(&user, "email = ?", "joiner@a.test").Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TenantID != f.tenantA.ID || user.Role != models.RoleModerator {
+		t.Errorf("joined tenant %s as %s, want %s as MODERATOR", user.TenantID, user.Role, f.tenantA.ID)
+	}
+
+	w = f.do(t, f.adminA, http.MethodPost, "/auth/accept-invite", gin.H{"token": inv.Token, "name": "Again", "password": "pw"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("second accept status = %d, want 400", w.Code)
+	}
+}
+
+func TestExistingAccountJoinsAnotherTenant(t *testing.T) {
+	f := newFixture(t)
+	hash, err := services.HashPassword("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Model(&models.User{}).Where("id = ?", f.adminB.ID).Update("password", string(hash)).Error; err != nil {
+		t.Fatal(err)
+	}
+	inv := f.invite(t, f.adminA, gin.H{"email": "Admin@B.test", "role": "MODERATOR"})
+
+	w := f.do(t, f.adminA, http.MethodPost, "/auth/accept-invite", gin.H{"token": inv.Token, "name": "Imposter", "password": "wrong"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("accept with wrong password status = %d, want 401", w.Code)
+	}
+	w = f.do(t, f.adminA, http.MethodPost, "/auth/accept-invite", gin.H{"token": inv.Token, "name": "Imposter", "password": "pw"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("accept status = %d, body %s", w.Code, w.Body)
+	}
+	var member models.User
+	if err := db.TenantUsers(context.Background(), f.tenantA.ID, false).First(&member, "id = ?", f.adminB.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if member.Role != models.RoleModerator || member.Name != "Admin B" {
+		t.Errorf("joined as %s named %q, want MODERATOR keeping the account name", member.Role, member.Name)
+	}
+
+	w = f.do(t, f.adminA, http.MethodPost, "/auth/login", gin.H{"email": "admin@b.test", "password": "pw"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d, body %s", w.Code, w.Body)
+	}
+	var login LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil {
+		t.Fatal(err)
+	}
+	if login.TenantID != f.tenantB.ID || len(login.Tenants) != 2 {
+		t.Errorf("login to %s with tenants %+v, want %s first of 2", login.TenantID, login.Tenants, f.tenantB.ID)
+	}
+}
+
+func TestSwitchTenant(t *testing.T) {
+	f := newFixture(t)
+	if err := db.DB.Create(&models.TenantMember{TenantID: f.tenantA.ID, UserID: f.memberB.ID, Role: models.RoleGuest}).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := f.do(t, f.memberB, http.MethodPost, "/auth/switch-tenant", gin.H{"tenant_id": f.tenantA.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("switch status = %d, body %s", w.Code, w.Body)
+	}
+	var resp LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := services.ParseAccessToken(context.Background(), resp.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["tenant_id"] != f.tenantA.ID || claims["role"] != string(models.RoleGuest) {
+		t.Errorf("token scoped to %v as %v, want %s as GUEST", claims["tenant_id"], claims["role"], f.tenantA.ID)
+	}
+
+	// Other tenants are off limits, and so is a tenant the user was removed from
+	if w := f.do(t, f.guestB, http.MethodPost, "/auth/switch-tenant", gin.H{"tenant_id": f.tenantA.ID}); w.Code != http.StatusForbidden {
+		t.Fatalf("switch to foreign tenant status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.adminA, http.MethodDelete, "/users/"+f.memberB.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("remove status = %d, body %s", w.Code, w.Body)
+	}
+	// Removal revoked the user's tokens, so pick up the new token version
+	db.DB.First(&f.memberB, "id = ?", f.memberB.ID)
+	if w := f.do(t, f.memberB, http.MethodPost, "/auth/switch-tenant", gin.H{"tenant_id": f.tenantA.ID}); w.Code != http.StatusForbidden {
+		t.Fatalf("switch to tenant after removal status = %d, want 403", w.Code)
+	}
+	// memberB still belongs to tenant B
+	if w := f.do(t, f.memberB, http.MethodGet, "/channels", nil); w.Code != http.StatusOK {
+		t.Fatalf("list channels in remaining tenant status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestRevokedInviteCannotBeAccepted(t *testing.T) {
+	f := newFixture(t)
+	inv := f.invite(t, f.adminA, gin.H{"email": "late@a.test"})
+	if w := f.do(t, f.adminB, http.MethodDelete, "/invites/"+inv.Invite.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant revoke status = %d, want 404", w.Code)
+	}
+	listed := func() int {
+		w := f.do(t, f.adminA, http.MethodGet, "/invites", nil)
+		var invites []models.Invite
+		if err := json.Unmarshal(w.Body.Bytes(), &invites); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("list invites status = %d, body %s", w.Code, w.Body)
+		}
+		return len(invites)
+	}
+	if n := listed(); n != 1 {
+		t.Fatalf("pending invites = %d, want 1", n)
+	}
+	if w := f.do(t, f.adminA, http.MethodDelete, "/invites/"+inv.Invite.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke status = %d, body %s", w.Code, w.Body)
+	}
+	if n := listed(); n != 0 {
+		t.Errorf("pending invites after revoke = %d, want 0", n)
+	}
+	w := f.do(t, f.adminA, http.MethodPost, "/auth/accept-invite", gin.H{"token": inv.Token, "name": "Late", "password": "pw"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("accept revoked status = %d, want 400", w.Code)
+	}
+}
+
+func TestInviteCannotGrantMoreThanInviter(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodPut, "/permissions/MODERATOR", gin.H{
+		"permissions": []models.Permission{models.PermUserList, models.PermUserInvite},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set permissions status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.modA, http.MethodPost, "/invites", gin.H{"email": "boss@a.test", "role": "ADMIN"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("invite admin status = %d, want 403", w.Code)
+	}
+}
+
+func TestInvitesFromMemoryRepos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := repository.NewMemory()
+	m.AddInvite(models.Invite{ID: "invite-a", TenantID: "tenant-a", Email: "new@a.test", Role: models.RoleMember, ExpiresAt: time.Now().Add(time.Hour)})
+	s := NewServer(m.Repos())
+	adminA := models.User{ID: "admin-a", TenantID: "tenant-a", Role: models.RoleAdmin}
+	adminB := models.User{ID: "admin-b", TenantID: "tenant-b", Role: models.RoleAdmin}
+	r := gin.New()
+	r.GET("/a/invites", asCaller(adminA), s.ListInvites)
+	r.DELETE("/a/invites/:id", asCaller(adminA), s.RevokeInvite)
+	r.DELETE("/b/invites/:id", asCaller(adminB), s.RevokeInvite)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := serve(http.MethodDelete, "/b/invites/invite-a"); w.Code != http.StatusNotFound {
+		t.Errorf("revoke from other tenant status = %d, want 404", w.Code)
+	}
+	w := serve(http.MethodGet, "/a/invites")
+	var invites []models.Invite
+	if err := json.Unmarshal(w.Body.Bytes(), &invites); err != nil || len(invites) != 1 {
+		t.Fatalf("list = %s, %v", w.Body, err)
+	}
+	if w := serve(http.MethodDelete, "/a/invites/invite-a"); w.Code != http.StatusOK {
+		t.Fatalf("revoke status = %d, body %s", w.Code, w.Body)
+	}
+	w = serve(http.MethodGet, "/a/invites")
+	if err := json.Unmarshal(w.Body.Bytes(), &invites); err != nil || len(invites) != 0 {
+		t.Errorf("list after revoke = %s, %v", w.Body, err)
+	}
+}
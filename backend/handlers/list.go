@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+)
+
+// listOptions parses the limit, cursor, sort and q query parameters shared by
+// list endpoints. On failure it writes a 400 response and returns false.
+func listOptions(c *gin.Context) (db.ListOptions, bool) {
+	opts := db.ListOptions{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Search: c.Query("q"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > db.MaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", db.MaxPageSize)})
+			return opts, false
+		}
+		opts.Limit = limit
+	}
+	return opts, true
+}
+
+// respondList writes the page returned by list for the request's list options
+func respondList[T any](c *gin.Context, list func(db.ListOptions) (*db.Page[T], error), what string) {
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+	page, err := list(opts)
+	if errors.Is(err, db.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported sort field"})
+		return
+	}
+	if errors.Is(err, db.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch " + what})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+)
+
+// mentionListSpec is the sorting supported by ListMyMentions
+var mentionListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"created_at": {Column: "created_at", Time: true},
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListMyMentions lists the messages mentioning the caller
+// @Summary List my mentions
+// @Description Lists the caller's mentions in their tenant, newest first, a page at a time. Each mention names the message (fetch it with GET /messages/{channel_id}) and how the caller was mentioned: user, channel or here.
+// @Tags users
+// @Produce json
+// @Param channel_id query string false "Only mentions in this Stream channel"
+// @Param kind query string false "Only mentions of this kind (user, channel, here)"
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "created_at or -created_at (default)"
+// @Success 200 {object} db.Page[models.Mention]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /me/mentions [get]
+func (s *Server) ListMyMentions(c *gin.Context) {
+	filter := repository.MentionFilter{
+		ChannelID: c.Query("channel_id"),
+		Kind:      models.MentionKind(c.Query("kind")),
+	}
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.Mention], error) {
+		return s.Mentions.List(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), filter, mentionListSpec, opts)
+	}, "mentions")
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+// recordingNotifier hands notifications to the test through sent; with an
+// unbuffered channel each delivery waits until the test receives it
+type recordingNotifier struct {
+	sent chan services.Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n services.Notification) error {
+	select {
+	case r.sent <- n:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// next waits for the next notification
+func (r *recordingNotifier) next(t *testing.T) services.Notification {
+	t.Helper()
+	select {
+	case n := <-r.sent:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification sent")
+		return services.Notification{}
+	}
+}
+
+func (f *fixture) mentions(t *testing.T, as models.User) []models.Mention {
+	t.Helper()
+	w := f.do(t, as, http.MethodGet, "/me/mentions", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("mentions status = %d, body %s", w.Code, w.Body)
+	}
+	var page db.Page[models.Mention]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	return page.Items
+}
+
+func TestParseMentions(t *testing.T) {
+	names, channel, here := services.ParseMentions("hi @Member, mail bob@b.test or @admin@b.test. @here @member")
+	if len(names) != 2 || names[0] != "member" || names[1] != "admin@b.test" || channel || !here {
+		t.Errorf("ParseMentions = %q, %v, %v", names, channel, here)
+	}
+}
+
+func TestMentionsAreRecordedAndNotified(t *testing.T) {
+	f := newFixture(t)
+	// Unbuffered, so sending only returns if notifications do not block it
+	notifier := &recordingNotifier{sent: make(chan services.Notification)}
+	services.SetNotifier(notifier)
+	defer services.SetNotifier(services.LogNotifier{})
+
+	// admin@a.test is in another tenant and GuestB's name resolves without spaces
+	msg := f.sendMessage(t, f.memberB, f.chanB, "@GuestB @admin@a.test please review")
+	mentions := f.mentions(t, f.guestB)
+	if len(mentions) != 1 || mentions[0].MessageID != msg.ID || mentions[0].Kind != models.MentionUser {
+		t.Fatalf("guest mentions = %+v", mentions)
+	}
+	if len(f.mentions(t, f.adminA)) != 0 {
+		t.Error("user of another tenant was mentioned")
+	}
+	if n := notifier.next(t); n.UserID != f.guestB.ID || n.MessageID != msg.ID {
+		t.Errorf("notification = %+v", n)
+	}
+
+	f.sendMessage(t, f.memberB, f.chanB, "@channel standup")
+	if got := f.mentions(t, f.adminB); len(got) != 1 || got[0].Kind != models.MentionChannel {
+		t.Errorf("admin mentions = %+v", got)
+	}
+	if got := f.mentions(t, f.memberB); len(got) != 0 {
+		t.Errorf("author mentioned themselves: %+v", got)
+	}
+	w := f.do(t, f.guestB, http.MethodGet, "/me/mentions?kind=channel", nil)
+	var page db.Page[models.Mention]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Errorf("guest channel mentions total = %d, want 1", page.Total)
+	}
+}
+
+func TestEditingMessageUpdatesMentions(t *testing.T) {
+	f := newFixture(t)
+	notifier := &recordingNotifier{sent: make(chan services.Notification)}
+	services.SetNotifier(notifier)
+	defer services.SetNotifier(services.LogNotifier{})
+	edit := func(msg models.Message, text string) {
+		t.Helper()
+		if w := f.do(t, f.memberB, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": text}); w.Code != http.StatusOK {
+			t.Fatalf("edit status = %d, body %s", w.Code, w.Body)
+		}
+	}
+
+	msg := f.sendMessage(t, f.memberB, f.chanB, "@GuestB can you look?")
+	notifier.next(t)
+	edit(msg, "@AdminB can you look?")
+	if got := f.mentions(t, f.guestB); len(got) != 0 {
+		t.Errorf("mention kept after it was edited out: %+v", got)
+	}
+	if got := f.mentions(t, f.adminB); len(got) != 1 || got[0].MessageID != msg.ID {
+		t.Errorf("admin mentions = %+v", got)
+	}
+	if n := notifier.next(t); n.UserID != f.adminB.ID || n.Text != "@AdminB can you look?" {
+		t.Errorf("notification = %+v", n)
+	}
+
+	// Users already mentioned are not notified again
+	edit(msg, "@AdminB can you look? @GuestB too")
+	if n := notifier.next(t); n.UserID != f.guestB.ID {
+		t.Errorf("notification = %+v, want only guest B", n)
+	}
+	if got := f.mentions(t, f.adminB); len(got) != 1 {
+		t.Errorf("admin mentions after second edit = %+v", got)
+	}
+}
+
+func TestSendMessageSucceedsWhenMentionsCannotBeRecorded(t *testing.T) {
+	f := newFixture(t)
+	if err := db.DB.Migrator().DropTable(&models.Mention{}); err != nil {
+		t.Fatal(err)
+	}
+	// The message is delivered before mentions are recorded, so sending must
+	// not fail and invite a duplicate resend
+	msg := f.sendMessage(t, f.memberB, f.chanB, "@GuestB hello")
+	if _, err := services.Chat().GetMessage(context.Background(), msg.ID); err != nil {
+		t.Errorf("sent message not found: %v", err)
+	}
+}
+
+func TestPrivateChannelOnlyMentionsMembers(t *testing.T) {
+	f := newFixture(t)
+	private := f.createChannel(t, f.adminB, "secret")
+	private.Type = models.ChannelPrivate
+	mustSave(t, &private)
+	w := f.do(t, f.adminB, http.MethodPost, "/messages", gin.H{"stream_id": private.StreamID, "text": "cc @member"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send status = %d, body %s", w.Code, w.Body)
+	}
+	if got := f.mentions(t, f.memberB); len(got) != 0 {
+		t.Errorf("non-member was mentioned: %+v", got)
+	}
+}
+
+func TestListMentionsFromMemoryRepos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := repository.NewMemory()
+	caller := models.User{ID: "user-1", TenantID: "tenant-a", Role: models.RoleMember}
+	for _, mention := range []models.Mention{
+		{TenantID: "tenant-a", UserID: "user-1", MessageID: "m1", ChannelID: "messaging:general", Kind: models.MentionUser},
+		{TenantID: "tenant-a", UserID: "user-1", MessageID: "m2", ChannelID: "messaging:random", Kind: models.MentionHere},
+		{TenantID: "tenant-a", UserID: "user-2", MessageID: "m1", ChannelID: "messaging:general", Kind: models.MentionUser},
+		{TenantID: "tenant-b", UserID: "user-1", MessageID: "m3", ChannelID: "messaging:general", Kind: models.MentionUser},
+	} {
+		m.AddMention(mention)
+	}
+	r := gin.New()
+	r.GET("/me/mentions", asCaller(caller), NewServer(m.Repos()).ListMyMentions)
+
+	for query, want := range map[string]int64{"": 2, "?kind=here": 1, "?channel_id=messaging:general": 1} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/mentions"+query, nil))
+		var page db.Page[models.Mention]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("%q: %s", query, w.Body)
+		}
+		if page.Total != want {
+			t.Errorf("%q total = %d, want %d", query, page.Total, want)
+		}
+	}
+}
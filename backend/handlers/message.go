@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// EditMessageRequest is the payload for editing a message
+type EditMessageRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// messageForCaller loads the message with the path's ID and its channel, which
+// must belong to the caller's tenant. On failure it writes the error response
+// and returns false.
+func (s *Server) messageForCaller(c *gin.Context) (*models.Message, *models.Channel, bool) {
+	msg, err := services.Chat().GetMessage(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch message"})
+		return nil, nil, false
+	}
+	channel, err := s.Channels.GetByStreamID(c.Request.Context(), c.GetString("tenant_id"), msg.ChannelID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channel"})
+		}
+		return nil, nil, false
+	}
+	return msg, channel, true
+}
+
+// checkMessageChange lets authors change their own live messages and holders
+// of perm change anyone's. On failure it writes the error response and returns false.
+func checkMessageChange(c *gin.Context, msg *models.Message, channel *models.Channel, perm models.Permission) bool {
+	if msg.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message was deleted"})
+		return false
+	}
+	if channel.ArchivedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Channel is archived"})
+		return false
+	}
+	if msg.UserID == c.GetString("user_id") {
+		return true
+	}
+	allowed, err := hasPermission(c, perm)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own messages"})
+		return false
+	}
+	return true
+}
+
+// EditMessage changes the text of a message
+// @Summary Edit a message
+// @Description Changes the text of a message. Authors may edit their own messages; message.edit_any allows editing any message of the tenant. The previous text is kept in the message history. Users mentioned for the first time are notified; mentions removed from the text are dropped.
+// @Tags stream
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param message body EditMessageRequest true "New text"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id} [put]
+func (s *Server) EditMessage(c *gin.Context) {
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	msg, channel, ok := s.messageForCaller(c)
+	if !ok || !checkMessageChange(c, msg, channel, models.PermMessageEditAny) {
+		return
+	}
+	ctx := c.Request.Context()
+	// Mentions stay attributed to the author, whoever edits the text
+	mentions, err := services.ResolveMentions(ctx, channel, msg.UserID, req.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not resolve mentions"})
+		return
+	}
+	updated, err := services.EditMessage(ctx, msg, c.GetString("user_id"), req.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not edit message"})
+		return
+	}
+	// As when sending, the edit is already visible, so this is only logged
+	if err := services.UpdateMentions(ctx, updated, mentions); err != nil {
+		log.Printf("update mentions of message %s: %v", msg.ID, err)
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteMessage deletes a message, leaving a tombstone
+// @Summary Delete a message
+// @Description Deletes a message; it stays in the channel as a tombstone without text. Authors may delete their own messages; message.delete_any allows deleting any message of the tenant.
+// @Tags stream
+// @Param id path string true "Message ID"
+// @Success 200 {object} map[string]bool
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id} [delete]
+func (s *Server) DeleteMessage(c *gin.Context) {
+	msg, channel, ok := s.messageForCaller(c)
+	if !ok || !checkMessageChange(c, msg, channel, models.PermMessageDeleteAny) {
+		return
+	}
+	if err := services.DeleteMessage(c.Request.Context(), msg, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete message"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// MessageHistory lists the earlier versions of a message (requires message.edit_any)
+// @Summary Get message edit history
+// @Description Lists the text a message had before each edit or deletion, oldest first
+// @Tags stream
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {array} models.MessageRevision
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id}/history [get]
+func (s *Server) MessageHistory(c *gin.Context) {
+	msg, _, ok := s.messageForCaller(c)
+	if !ok {
+		return
+	}
+	revisions, err := services.MessageHistory(c.Request.Context(), msg.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch message history"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// RepliesResponse is a page of a thread's replies plus the users taking part in it
+type RepliesResponse struct {
+	MessagesResponse
+	Participants []string `json:"participants"`
+}
+
+// GetReplies fetches a page of the replies to a message
+// @Summary Get thread replies
+// @Description Retrieves a page of the replies to a top-level message, oldest first, with the IDs of the thread participants. Pagination works as for GET /messages/{id}.
+// @Tags stream
+// @Produce json
+// @Param id path string true "Parent message ID"
+// @Param limit query int false "Page size (default 25, max 100)"
+// @Param before_id query string false "Only replies older than this message ID"
+// @Param after_id query string false "Only replies newer than this message ID"
+// @Param before query string false "Only replies created before this RFC 3339 timestamp"
+// @Param after query string false "Only replies created after this RFC 3339 timestamp"
+// @Success 200 {object} RepliesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id}/replies [get]
+func (s *Server) GetReplies(c *gin.Context) {
+	q, ok := messageQuery(c)
+	if !ok {
+		return
+	}
+	parent, channel, ok := s.messageForCaller(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if channel.Type.MembersOnly() {
+		member, err := services.IsChannelMember(ctx, channel.ID, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check channel membership"})
+			return
+		}
+		if !member {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this channel"})
+			return
+		}
+	}
+	page, err := services.Chat().GetReplies(ctx, parent.ID, q)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown message cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch replies"})
+		return
+	}
+	if !decorateMessages(c, page.Messages) {
+		return
+	}
+	participants, err := services.ThreadParticipants(ctx, parent.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch thread participants"})
+		return
+	}
+	if participants == nil {
+		participants = []string{}
+	}
+	c.JSON(http.StatusOK, RepliesResponse{MessagesResponse: newMessagesResponse(page), Participants: participants})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+	"github.com/gin-gonic/gin"
+)
+
+// sendMessage posts text to channel as user and returns the created message
+func (f *fixture) sendMessage(t *testing.T, as models.User, channel models.Channel, text string) models.Message {
+	t.Helper()
+	w := f.do(t, as, http.MethodPost, "/messages", gin.H{"stream_id": channel.StreamID, "text": text})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send status = %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Message models.Message `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Message
+}
+
+func TestAuthorCanEditOwnMessage(t *testing.T) {
+	f := newFixture(t)
+	msg := f.sendMessage(t, f.memberB, f.chanB, "helo")
+	w := f.do(t, f.memberB, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": "hello"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var edited models.Message
+	if err := json.Unmarshal(w.Body.Bytes(), &edited); err != nil {
+		t.Fatal(err)
+	}
+	if edited.Text != "hello" || edited.EditedAt == nil {
+		t.Errorf("edited message = %+v", edited)
+	}
+
+	w = f.do(t, f.guestB, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": "hijacked"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("other user's edit status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.adminA, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": "hijacked"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("cross-tenant edit status = %d, want 404", w.Code)
+	}
+}
+
+func TestModeratorDeleteLeavesTombstoneAndHistory(t *testing.T) {
+	f := newFixture(t)
+	msg := f.sendMessage(t, f.memberB, f.chanB, "first")
+	if w := f.do(t, f.memberB, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": "second"}); w.Code != http.StatusOK {
+		t.Fatalf("edit status = %d, body %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/messages/"+msg.ID+"/history", nil); w.Code != http.StatusForbidden {
+		t.Errorf("member history status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodDelete, "/messages/"+msg.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", w.Code, w.Body)
+	}
+
+	w := f.do(t, f.guestB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	var page MessagesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].DeletedAt == nil || page.Messages[0].Text != "" {
+		t.Errorf("want a tombstone without text, got %+v", page.Messages)
+	}
+
+	w = f.do(t, f.adminB, http.MethodGet, "/messages/"+msg.ID+"/history", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("history status = %d, body %s", w.Code, w.Body)
+	}
+	var revisions []models.MessageRevision
+	if err := json.Unmarshal(w.Body.Bytes(), &revisions); err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Text != "first" || revisions[1].Text != "second" ||
+		revisions[1].Action != models.RevisionDelete {
+		t.Errorf("history = %+v", revisions)
+	}
+	if w := f.do(t, f.memberB, http.MethodPut, "/messages/"+msg.ID, gin.H{"text": "back"}); w.Code != http.StatusConflict {
+		t.Errorf("edit of deleted message status = %d, want 409", w.Code)
+	}
+}
+
+func TestDeletingReplyUpdatesReplyCount(t *testing.T) {
+	f := newFixture(t)
+	parent := f.sendMessage(t, f.adminB, f.chanB, "question")
+	var replyIDs []string
+	for _, text := range []string{"a1", "a2"} {
+		w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": text, "parent_id": parent.ID})
+		var resp struct {
+			Message models.Message `json:"message"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("reply status = %d, body %s", w.Code, w.Body)
+		}
+		replyIDs = append(replyIDs, resp.Message.ID)
+	}
+	for i := 0; i < 2; i++ {
+		// The second delete of the same reply must not count it again
+		w := f.do(t, f.memberB, http.MethodDelete, "/messages/"+replyIDs[0], nil)
+		if want := []int{http.StatusOK, http.StatusConflict}[i]; w.Code != want {
+			t.Fatalf("delete #%d status = %d, want %d, body %s", i+1, w.Code, want, w.Body)
+		}
+	}
+	got, err := services.Chat().GetMessage(context.Background(), parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ReplyCount != 1 {
+		t.Errorf("reply count = %d, want 1", got.ReplyCount)
+	}
+}
+
+func TestThreadedReplies(t *testing.T) {
+	f := newFixture(t)
+	parent := f.sendMessage(t, f.adminB, f.chanB, "question")
+	for _, text := range []string{"a1", "a2", "a3"} {
+		w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": text, "parent_id": parent.ID})
+		if w.Code != http.StatusOK {
+			t.Fatalf("reply status = %d, body %s", w.Code, w.Body)
+		}
+	}
+
+	w := f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	var channelPage MessagesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &channelPage); err != nil {
+		t.Fatal(err)
+	}
+	if len(channelPage.Messages) != 1 || channelPage.Messages[0].ReplyCount != 3 {
+		t.Fatalf("channel shows %+v, want only the parent with 3 replies", channelPage.Messages)
+	}
+
+	w = f.do(t, f.guestB, http.MethodGet, "/messages/"+parent.ID+"/replies?limit=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("replies status = %d, body %s", w.Code, w.Body)
+	}
+	var replies RepliesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &replies); err != nil {
+		t.Fatal(err)
+	}
+	if len(replies.Messages) != 2 || replies.Messages[1].Text != "a3" || replies.PrevCursor == nil {
+		t.Errorf("first replies page = %+v", replies.MessagesResponse)
+	}
+	if len(replies.Participants) != 2 {
+		t.Errorf("participants = %v, want the parent author and the replier", replies.Participants)
+	}
+
+	reply := replies.Messages[0]
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "nested", "parent_id": reply.ID})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("nested reply status = %d, want 400", w.Code)
+	}
+	other := f.createChannel(t, f.adminB, "other", f.memberB)
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": other.StreamID, "text": "x", "parent_id": parent.ID})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("reply across channels status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// RolePermissionsRequest is the complete permission set for a role
+type RolePermissionsRequest struct {
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// RolePermissions is the effective permission set of a role in the caller's tenant
+type RolePermissions struct {
+	Role        models.Role         `json:"role"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// ListPermissions returns the tenant's permission matrix
+// @Summary List role permissions
+// @Description Lists every known permission and the effective permissions of each built-in and custom role in the caller's tenant
+// @Tags permissions
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /permissions [get]
+func (s *Server) ListPermissions(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	custom, err := s.Roles.List(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch roles"})
+		return
+	}
+	all := append([]models.Role{}, models.BuiltinRoles...)
+	for _, r := range custom {
+		all = append(all, models.Role(r.Name))
+	}
+	roles := make([]RolePermissions, 0, len(all))
+	for _, role := range all {
+		perms, err := services.EffectivePermissions(c.Request.Context(), tenantID, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch permissions"})
+			return
+		}
+		roles = append(roles, RolePermissions{Role: role, Permissions: services.PermissionList(perms)})
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": models.AllPermissions, "roles": roles})
+}
+
+// SetRolePermissions replaces a role's permissions in the caller's tenant
+// @Summary Set role permissions
+// @Description Replaces the permission set of a role for the caller's tenant (Admin only)
+// @Tags permissions
+// @Accept json
+// @Produce json
+// @Param role path string true "Role"
+// @Param permissions body RolePermissionsRequest true "Permissions"
+// @Success 200 {object} RolePermissions
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /permissions/{role} [put]
+func (s *Server) SetRolePermissions(c *gin.Context) {
+	role, ok := permissionRole(c)
+	if !ok {
+		return
+	}
+	var req RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !validPermissions(c, req.Permissions) {
+		return
+	}
+	if role == models.RoleAdmin && !containsPermission(req.Permissions, models.PermPermissionManage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ADMIN must keep " + string(models.PermPermissionManage)})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	if err := services.SetRolePermissions(c.Request.Context(), tenantID, role, req.Permissions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update permissions"})
+		return
+	}
+	respondRolePermissions(c, tenantID, role)
+}
+
+// ResetRolePermissions restores a role's default permissions in the caller's tenant
+// @Summary Reset role permissions
+// @Description Drops the tenant's overrides so the role has its default permissions again (Admin only)
+// @Tags permissions
+// @Produce json
+// @Param role path string true "Role"
+// @Success 200 {object} RolePermissions
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /permissions/{role} [delete]
+func (s *Server) ResetRolePermissions(c *gin.Context) {
+	role, ok := permissionRole(c)
+	if !ok {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	if err := services.ResetRolePermissions(c.Request.Context(), tenantID, role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset permissions"})
+		return
+	}
+	respondRolePermissions(c, tenantID, role)
+}
+
+// permissionRole validates the :role path parameter (built-in or custom role)
+func permissionRole(c *gin.Context) (models.Role, bool) {
+	role := models.Role(c.Param("role"))
+	exists, err := services.RoleExists(c.Request.Context(), c.GetString("tenant_id"), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch role"})
+		return "", false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + string(role)})
+		return "", false
+	}
+	return role, true
+}
+
+func respondRolePermissions(c *gin.Context, tenantID string, role models.Role) {
+	perms, err := services.EffectivePermissions(c.Request.Context(), tenantID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch permissions"})
+		return
+	}
+	c.JSON(http.StatusOK, RolePermissions{Role: role, Permissions: services.PermissionList(perms)})
+}
+
+func containsPermission(perms []models.Permission, perm models.Permission) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
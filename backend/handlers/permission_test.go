@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTenantOverrideChangesModeratorPermissions(t *testing.T) {
+	f := newFixture(t)
+	newUser := gin.H{"name": "New", "email": "new@a.test", "password": "pw"}
+	w := f.do(t, f.adminA, http.MethodPut, "/permissions/MODERATOR", gin.H{
+		"permissions": []models.Permission{models.PermUserList, models.PermMessageRead},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set permissions status = %d, body %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.modA, http.MethodPost, "/users", newUser); w.Code != http.StatusForbidden {
+		t.Fatalf("moderator create user status = %d, want 403", w.Code)
+	}
+}
+
+func TestOverridesDoNotLeakAcrossTenants(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodPut, "/permissions/MEMBER", gin.H{"permissions": []models.Permission{}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set permissions status = %d, body %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/users", nil); w.Code != http.StatusOK {
+		t.Fatalf("tenant B member list users status = %d, want 200", w.Code)
+	}
+}
+
+func TestAdminMustKeepPermissionManage(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodPut, "/permissions/ADMIN", gin.H{"permissions": []models.Permission{models.PermUserList}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+}
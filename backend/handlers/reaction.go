@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// ReactionRequest is the payload for adding or removing a reaction
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required,max=64"`
+}
+
+// reactionTarget binds the request and loads the message the caller reacts to.
+// Reacting requires membership of the message's channel, which must not be
+// archived. On failure it writes the error response and returns false.
+func (s *Server) reactionTarget(c *gin.Context) (*models.Message, string, bool) {
+	var req ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return nil, "", false
+	}
+	msg, channel, ok := s.messageForCaller(c)
+	if !ok {
+		return nil, "", false
+	}
+	if msg.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message was deleted"})
+		return nil, "", false
+	}
+	if channel.ArchivedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Channel is archived"})
+		return nil, "", false
+	}
+	member, err := services.IsChannelMember(c.Request.Context(), channel.ID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check channel membership"})
+		return nil, "", false
+	}
+	if !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Join this channel before reacting"})
+		return nil, "", false
+	}
+	return msg, req.Emoji, true
+}
+
+// AddReaction adds the caller's emoji reaction to a message
+// @Summary React to a message
+// @Description Adds an emoji reaction from the caller to a message of their tenant. The caller must be a member of the message's channel. Reacting twice with the same emoji has no effect. Returns the message's reaction counts.
+// @Tags stream
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param reaction body ReactionRequest true "Emoji"
+// @Success 200 {array} models.ReactionSummary
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id}/reactions [post]
+func (s *Server) AddReaction(c *gin.Context) {
+	msg, emoji, ok := s.reactionTarget(c)
+	if !ok {
+		return
+	}
+	if err := services.AddReaction(c.Request.Context(), msg, c.GetString("user_id"), emoji); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add reaction"})
+		return
+	}
+	respondReactions(c, msg)
+}
+
+// RemoveReaction removes the caller's emoji reaction from a message
+// @Summary Remove a reaction
+// @Description Removes the caller's emoji reaction from a message; removing a missing reaction has no effect. Returns the message's reaction counts.
+// @Tags stream
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param reaction body ReactionRequest true "Emoji"
+// @Success 200 {array} models.ReactionSummary
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /messages/{id}/reactions [delete]
+func (s *Server) RemoveReaction(c *gin.Context) {
+	msg, emoji, ok := s.reactionTarget(c)
+	if !ok {
+		return
+	}
+	if err := services.RemoveReaction(c.Request.Context(), msg, c.GetString("user_id"), emoji); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not remove reaction"})
+		return
+	}
+	respondReactions(c, msg)
+}
+
+func respondReactions(c *gin.Context, msg *models.Message) {
+	messages := []models.Message{*msg}
+	if err := services.AttachReactions(c.Request.Context(), messages, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reactions"})
+		return
+	}
+	c.JSON(http.StatusOK, messages[0].Reactions)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReactionsAreAggregatedPerEmoji(t *testing.T) {
+	f := newFixture(t)
+	msg := f.sendMessage(t, f.memberB, f.chanB, "ship it")
+	for _, r := range []struct {
+		as    models.User
+		emoji string
+	}{{f.memberB, "👍"}, {f.guestB, "👍"}, {f.guestB, "👍"}, {f.adminB, "🎉"}} {
+		if w := f.do(t, r.as, http.MethodPost, "/messages/"+msg.ID+"/reactions", gin.H{"emoji": r.emoji}); w.Code != http.StatusOK {
+			t.Fatalf("react status = %d, body %s", w.Code, w.Body)
+		}
+	}
+	if w := f.do(t, f.adminA, http.MethodPost, "/messages/"+msg.ID+"/reactions", gin.H{"emoji": "👎"}); w.Code != http.StatusNotFound {
+		t.Errorf("cross-tenant react status = %d, want 404", w.Code)
+	}
+
+	w := f.do(t, f.guestB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	var page MessagesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ReactionSummary{{Emoji: "👍", Count: 2, Reacted: true}, {Emoji: "🎉", Count: 1}}
+	if len(page.Messages) != 1 || len(page.Messages[0].Reactions) != 2 ||
+		page.Messages[0].Reactions[0] != want[0] || page.Messages[0].Reactions[1] != want[1] {
+		t.Errorf("reactions = %+v, want %+v", page.Messages, want)
+	}
+
+	w = f.do(t, f.guestB, http.MethodDelete, "/messages/"+msg.ID+"/reactions", gin.H{"emoji": "👍"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unreact status = %d, body %s", w.Code, w.Body)
+	}
+	var summaries []models.ReactionSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[1] != (models.ReactionSummary{Emoji: "👍", Count: 1}) {
+		t.Errorf("after removal = %+v", summaries)
+	}
+}
+
+func TestReactingRequiresMembership(t *testing.T) {
+	f := newFixture(t)
+	other := models.User{Name: "Other B", Email: "other@b.test", Password: "x", Role: models.RoleMember, TenantID: f.tenantB.ID}
+	mustCreate(t, &other)
+	msg := f.sendMessage(t, f.memberB, f.chanB, "hi")
+	if w := f.do(t, other, http.MethodPost, "/messages/"+msg.ID+"/reactions", gin.H{"emoji": "👋"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-member react status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.memberB, http.MethodDelete, "/messages/"+msg.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d", w.Code)
+	}
+	if w := f.do(t, f.guestB, http.MethodPost, "/messages/"+msg.ID+"/reactions", gin.H{"emoji": "👋"}); w.Code != http.StatusConflict {
+		t.Errorf("react to deleted message status = %d, want 409", w.Code)
+	}
+}
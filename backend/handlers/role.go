@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// RoleRequest is the payload for creating or updating a custom role.
+// On update, omitted fields are left unchanged.
+type RoleRequest struct {
+	Name        string               `json:"name"`
+	Description *string              `json:"description"`
+	Permissions *[]models.Permission `json:"permissions"`
+}
+
+// RoleResponse describes a built-in or custom role with its effective permissions
+type RoleResponse struct {
+	ID          string              `json:"id,omitempty"`
+	Name        models.Role         `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Builtin     bool                `json:"builtin"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// ListRoles lists the built-in and custom roles of the caller's tenant
+// @Summary List roles
+// @Description Lists the built-in roles and the tenant's custom roles with their permissions
+// @Tags roles
+// @Produce json
+// @Success 200 {array} RoleResponse
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /roles [get]
+func (s *Server) ListRoles(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	custom, err := s.Roles.List(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch roles"})
+		return
+	}
+	roles := make([]RoleResponse, 0, len(models.BuiltinRoles)+len(custom))
+	for _, r := range models.BuiltinRoles {
+		resp, err := roleResponse(c, models.TenantRole{Name: string(r)}, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch roles"})
+			return
+		}
+		roles = append(roles, resp)
+	}
+	for _, r := range custom {
+		resp, err := roleResponse(c, r, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch roles"})
+			return
+		}
+		roles = append(roles, resp)
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole creates a custom role in the caller's tenant
+// @Summary Create role
+// @Description Creates a custom role (e.g. "Support Agent") with the given permissions (Admin only)
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param role body RoleRequest true "Role info"
+// @Success 201 {object} RoleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /roles [post]
+func (s *Server) CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name is required"})
+		return
+	}
+	var perms []models.Permission
+	if req.Permissions != nil {
+		perms = *req.Permissions
+	}
+	if !validPermissions(c, perms) {
+		return
+	}
+	role := models.TenantRole{TenantID: c.GetString("tenant_id"), Name: req.Name}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	err := services.CreateTenantRole(c.Request.Context(), &role, perms)
+	if errors.Is(err, services.ErrRoleNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role name already in use"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create role"})
+		return
+	}
+	respondRole(c, http.StatusCreated, role)
+}
+
+// UpdateRole renames a custom role or changes its description or permissions
+// @Summary Update role
+// @Description Updates a custom role of the caller's tenant; renaming moves its users along and revokes their tokens (Admin only)
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param id path string true "Role ID"
+// @Param role body RoleRequest true "Role info"
+// @Success 200 {object} RoleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /roles/{id} [put]
+func (s *Server) UpdateRole(c *gin.Context) {
+	role, ok := s.findTenantRole(c)
+	if !ok {
+		return
+	}
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Permissions != nil && !validPermissions(c, *req.Permissions) {
+		return
+	}
+	ctx := c.Request.Context()
+	if name := strings.TrimSpace(req.Name); name != "" {
+		userIDs, err := services.RenameTenantRole(ctx, &role, name)
+		if errors.Is(err, services.ErrRoleNameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role name already in use"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not rename role"})
+			return
+		}
+		// Tokens carry the role name, so holders of the old name must log in again
+		for _, id := range userIDs {
+			if err := services.RevokeUserTokens(ctx, id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke user tokens"})
+				return
+			}
+		}
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+		if err := s.Roles.UpdateDescription(ctx, &role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update role"})
+			return
+		}
+	}
+	if req.Permissions != nil {
+		err := services.SetRolePermissions(ctx, role.TenantID, models.Role(role.Name), *req.Permissions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update permissions"})
+			return
+		}
+	}
+	respondRole(c, http.StatusOK, role)
+}
+
+// DeleteRole deletes an unassigned custom role
+// @Summary Delete role
+// @Description Deletes a custom role of the caller's tenant; fails while users still have it (Admin only)
+// @Tags roles
+// @Param id path string true "Role ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /roles/{id} [delete]
+func (s *Server) DeleteRole(c *gin.Context) {
+	role, ok := s.findTenantRole(c)
+	if !ok {
+		return
+	}
+	err := services.DeleteTenantRole(c.Request.Context(), role)
+	if errors.Is(err, services.ErrRoleInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete role"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// findTenantRole loads the custom role :id of the caller's tenant
+func (s *Server) findTenantRole(c *gin.Context) (models.TenantRole, bool) {
+	role, err := s.Roles.Get(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch role"})
+		}
+		return models.TenantRole{}, false
+	}
+	return *role, true
+}
+
+func validPermissions(c *gin.Context, perms []models.Permission) bool {
+	for _, p := range perms {
+		if !models.IsValidPermission(p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + string(p)})
+			return false
+		}
+	}
+	return true
+}
+
+func roleResponse(c *gin.Context, role models.TenantRole, builtin bool) (RoleResponse, error) {
+	perms, err := services.EffectivePermissions(c.Request.Context(), c.GetString("tenant_id"), models.Role(role.Name))
+	if err != nil {
+		return RoleResponse{}, err
+	}
+	return RoleResponse{
+		ID:          role.ID,
+		Name:        models.Role(role.Name),
+		Description: role.Description,
+		Builtin:     builtin,
+		Permissions: services.PermissionList(perms),
+	}, nil
+}
+
+func respondRole(c *gin.Context, status int, role models.TenantRole) {
+	resp, err := roleResponse(c, role, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch permissions"})
+		return
+	}
+	c.JSON(status, resp)
+}
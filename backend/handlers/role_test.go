@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCustomRoleGrantsItsPermissions(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPost, "/roles", gin.H{
+		"name":        "Support Agent",
+		"permissions": []models.Permission{models.PermUserList, models.PermUserCreate, models.PermMessageRead},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create role status = %d, body %s", w.Code, w.Body)
+	}
+	var role RoleResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &role); err != nil {
+		t.Fatal(err)
+	}
+
+	w = f.do(t, f.adminB, http.MethodPut, "/users/"+f.memberB.ID, gin.H{"role": "Support Agent"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("assign role status = %d, body %s", w.Code, w.Body)
+	}
+	var agent models.User
+	if err := db.TenantUsers(context.Background(), f.tenantB.ID, false).First(&agent, "id = ?", f.memberB.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w = f.do(t, agent, http.MethodPost, "/users", gin.H{"name": "Guest", "email": "g2@b.test", "password": "pw", "role": "GUEST"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("agent create guest status = %d, body %s", w.Code, w.Body)
+	}
+	// MODERATOR has permissions the agent lacks
+	w = f.do(t, agent, http.MethodPost, "/users", gin.H{"name": "Mod", "email": "m2@b.test", "password": "pw", "role": "MODERATOR"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("agent create moderator status = %d, want 403", w.Code)
+	}
+
+	if w := f.do(t, f.adminB, http.MethodDelete, "/roles/"+role.ID, nil); w.Code != http.StatusConflict {
+		t.Fatalf("delete assigned role status = %d, want 409", w.Code)
+	}
+}
+
+func TestCustomRoleIsTenantScoped(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPost, "/roles", gin.H{"name": "Contractor"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create role status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.adminA, http.MethodPut, "/users/"+f.modA.ID, gin.H{"role": "Contractor"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("assign other tenant's role status = %d, want 400", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodPost, "/roles", gin.H{"name": "admin"}); w.Code != http.StatusConflict {
+		t.Fatalf("shadowing built-in role status = %d, want 409", w.Code)
+	}
+
+	for _, as := range []models.User{f.adminB, f.adminA} {
+		w = f.do(t, as, http.MethodGet, "/roles", nil)
+		var roles []RoleResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &roles); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("list roles status = %d, body %s", w.Code, w.Body)
+		}
+		listed := false
+		for _, r := range roles {
+			listed = listed || r.Name == "Contractor"
+		}
+		if want := as.TenantID == f.tenantB.ID; listed != want || len(roles) < len(models.BuiltinRoles) {
+			t.Errorf("roles of %s = %+v", as.Email, roles)
+		}
+	}
+}
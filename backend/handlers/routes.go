@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"github.com/Tabintel/multi-tenant-chat/backend/middleware"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes registers every API endpoint on r with its authentication and
+// permission middleware. cmd/main.go and the handler tests share it, so the
+// tests exercise the same routes as the server.
+func (s *Server) Routes(r gin.IRouter) {
+	// Auth endpoints
+	r.POST("/auth/login", s.Login)
+	r.POST("/auth/register", s.Register)
+	r.POST("/auth/refresh", s.Refresh)
+	r.POST("/auth/logout", middleware.JWTAuth(), s.Logout)
+	r.POST("/auth/switch-tenant", middleware.JWTAuth(), s.SwitchTenant)
+	r.POST("/auth/accept-invite", s.AcceptInvite)
+
+	// Stream Chat token endpoint (protected)
+	r.GET("/stream/token", middleware.JWTAuth(), s.StreamToken)
+
+	// Tenant endpoints
+	// Creating, listing and restoring tenants needs tenant.create; deleting one needs tenant.manage (own tenant only)
+	r.POST("/tenants", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantCreate), s.CreateTenant)
+	r.GET("/tenants", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantCreate), s.ListTenants)
+	r.DELETE("/tenants/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantManage), s.DeleteTenant)
+	r.GET("/tenants/deleted", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantCreate), s.ListDeletedTenants)
+	r.POST("/tenants/:id/restore", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantCreate), s.RestoreTenant)
+
+	// User endpoints (permissions default to Admin/Moderator for create/update, Admin for delete, all roles for list)
+	r.POST("/users", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserCreate), s.CreateUser)
+	r.GET("/users", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserList), s.ListUsers)
+	r.PUT("/users/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserUpdate), s.UpdateUser)
+	r.DELETE("/users/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserDelete), s.DeleteUser)
+	r.GET("/users/deleted", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserDelete), s.ListDeletedUsers)
+	r.POST("/users/:id/restore", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserDelete), s.RestoreUser)
+
+	// Channel endpoints (Admin/Moderator for create by default, all roles for list)
+	r.POST("/channels", middleware.JWTAuth(), middleware.RequirePermission(models.PermChannelCreate), s.CreateChannel)
+	r.GET("/channels", middleware.JWTAuth(), s.ListChannels)
+	r.POST("/channels/:id/join", middleware.JWTAuth(), s.JoinChannel)
+	// Update needs channel.manage or channel owner/moderator; archive/delete need channel.manage or owner
+	r.PUT("/channels/:id", middleware.JWTAuth(), s.UpdateChannel)
+	r.POST("/channels/:id/archive", middleware.JWTAuth(), s.ArchiveChannel)
+	r.DELETE("/channels/:id", middleware.JWTAuth(), s.DeleteChannel)
+	r.GET("/channels/deleted", middleware.JWTAuth(), middleware.RequirePermission(models.PermChannelManage), s.ListDeletedChannels)
+	r.POST("/channels/:id/restore", middleware.JWTAuth(), middleware.RequirePermission(models.PermChannelManage), s.RestoreChannel)
+	// Channel membership (channel.manage or channel owner/moderator to change, members to list)
+	r.POST("/channels/:id/members", middleware.JWTAuth(), s.AddChannelMembers)
+	r.DELETE("/channels/:id/members", middleware.JWTAuth(), s.RemoveChannelMembers)
+	r.GET("/channels/:id/members", middleware.JWTAuth(), s.ListChannelMembers)
+
+	// Direct conversations (DMs and group DMs); messages go through /messages
+	r.POST("/conversations", middleware.JWTAuth(), middleware.RequirePermission(models.PermMessageSend), s.CreateConversation)
+	r.GET("/conversations", middleware.JWTAuth(), s.ListConversations)
+
+	// Messages endpoint (all authenticated users; sending is checked per channel,
+	// editing/deleting others' messages needs message.edit_any/message.delete_any)
+	r.POST("/messages", middleware.JWTAuth(), s.SendMessage)
+	r.GET("/messages/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermMessageRead), s.GetMessages)
+	r.PUT("/messages/:id", middleware.JWTAuth(), s.EditMessage)
+	r.DELETE("/messages/:id", middleware.JWTAuth(), s.DeleteMessage)
+	r.GET("/messages/:id/replies", middleware.JWTAuth(), middleware.RequirePermission(models.PermMessageRead), s.GetReplies)
+	r.GET("/messages/:id/history", middleware.JWTAuth(), middleware.RequirePermission(models.PermMessageEditAny), s.MessageHistory)
+	r.POST("/messages/:id/reactions", middleware.JWTAuth(), s.AddReaction)
+	r.DELETE("/messages/:id/reactions", middleware.JWTAuth(), s.RemoveReaction)
+	r.PUT("/me", middleware.JWTAuth(), s.UpdateAccount)
+	r.GET("/me/mentions", middleware.JWTAuth(), s.ListMyMentions)
+	r.GET("/search/messages", middleware.JWTAuth(), middleware.RequirePermission(models.PermMessageRead), s.SearchMessages)
+
+	// Attachments (uploads are checked per channel like messages; downloads use signed URLs)
+	r.POST("/attachments", middleware.JWTAuth(), s.UploadAttachment)
+	r.GET("/attachments/:id/download", s.DownloadAttachment)
+	r.GET("/attachment-policy", middleware.JWTAuth(), s.GetAttachmentPolicy)
+	r.PUT("/attachment-policy", middleware.JWTAuth(), middleware.RequirePermission(models.PermTenantManage), s.SetAttachmentPolicy)
+
+	// Permission matrix endpoints (Admin by default)
+	r.GET("/permissions", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.ListPermissions)
+	r.PUT("/permissions/:role", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.SetRolePermissions)
+	r.DELETE("/permissions/:role", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.ResetRolePermissions)
+
+	// Invitation endpoints (Admin by default)
+	r.POST("/invites", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserInvite), s.CreateInvite)
+	r.GET("/invites", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserInvite), s.ListInvites)
+	r.DELETE("/invites/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermUserInvite), s.RevokeInvite)
+
+	// Custom role endpoints (Admin by default)
+	r.GET("/roles", middleware.JWTAuth(), s.ListRoles)
+	r.POST("/roles", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.CreateRole)
+	r.PUT("/roles/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.UpdateRole)
+	r.DELETE("/roles/:id", middleware.JWTAuth(), middleware.RequirePermission(models.PermPermissionManage), s.DeleteRole)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// canAssignRole reports whether the caller may give role to a user (or
+// modify a user holding it): only admins may assign ADMIN, and nobody may
+// hand out permissions they do not have themselves
+func canAssignRole(c *gin.Context, role models.Role) (bool, error) {
+	callerRole := models.Role(c.GetString("user_role"))
+	if callerRole == models.RoleAdmin {
+		return true, nil
+	}
+	if role == models.RoleAdmin {
+		return false, nil
+	}
+	ctx := c.Request.Context()
+	tenantID := c.GetString("tenant_id")
+	mine, err := services.EffectivePermissions(ctx, tenantID, callerRole)
+	if err != nil {
+		return false, err
+	}
+	theirs, err := services.EffectivePermissions(ctx, tenantID, role)
+	if err != nil {
+		return false, err
+	}
+	for p := range theirs {
+		if !mine[p] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkAssignableRole verifies that role exists in the caller's tenant and
+// that the caller may assign it. On failure it writes the error response.
+func checkAssignableRole(c *gin.Context, role models.Role) bool {
+	exists, err := services.RoleExists(c.Request.Context(), c.GetString("tenant_id"), role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch role"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + string(role)})
+		return false
+	}
+	allowed, err := canAssignRole(c, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+	return true
+}
+
+// hasPermission reports whether the caller's role has perm in their tenant
+func hasPermission(c *gin.Context, perm models.Permission) (bool, error) {
+	role := models.Role(c.GetString("user_role"))
+	return services.HasPermission(c.Request.Context(), c.GetString("tenant_id"), role, perm)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
+)
+
+// SearchResponse is a page of search results, best matches first.
+// NextCursor is passed as cursor for the next page and is null on the last one.
+type SearchResponse struct {
+	Messages   []models.Message `json:"messages"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+// searchQuery parses the parameters of SearchMessages. The has:attachment
+// operator may also be written in q. On failure it writes a 400 response and returns false.
+func searchQuery(c *gin.Context) (services.SearchQuery, bool) {
+	q := services.SearchQuery{
+		UserID:        c.Query("author_id"),
+		Cursor:        c.Query("cursor"),
+		HasAttachment: c.Query("has") == "attachment",
+	}
+	var words []string
+	for _, word := range strings.Fields(c.Query("q")) {
+		if strings.EqualFold(word, "has:attachment") {
+			q.HasAttachment = true
+		} else {
+			words = append(words, word)
+		}
+	}
+	q.Text = strings.Join(words, " ")
+	if q.Text == "" && !q.HasAttachment && q.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q, author_id or has=attachment is required"})
+		return q, false
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > services.MaxMessageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxMessageLimit)})
+			return q, false
+		}
+		q.Limit = limit
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"before", &q.Before}, {"after", &q.After}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be an RFC 3339 timestamp"})
+			return q, false
+		}
+		*p.dst = &t
+	}
+	return q, true
+}
+
+// SearchMessages searches the messages the caller can read
+// @Summary Search messages
+// @Description Full-text search over the messages of the channels the caller can read in their tenant: public and announcement channels plus the private channels and conversations they are a member of. Deleted messages are never returned.
+// @Tags stream
+// @Produce json
+// @Param q query string false "Search text; may contain has:attachment"
+// @Param channel_id query string false "Only this Stream channel"
+// @Param author_id query string false "Only messages by this user"
+// @Param before query string false "Only messages created before this RFC 3339 timestamp"
+// @Param after query string false "Only messages created after this RFC 3339 timestamp"
+// @Param has query string false "attachment: only messages with attachments"
+// @Param limit query int false "Page size (default 25, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /search/messages [get]
+func (s *Server) SearchMessages(c *gin.Context) {
+	q, ok := searchQuery(c)
+	if !ok {
+		return
+	}
+	if streamID := c.Query("channel_id"); streamID != "" {
+		if _, _, ok := s.channelForCaller(c, streamID); !ok {
+			return
+		}
+		q.ChannelIDs = []string{streamID}
+	} else {
+		var err error
+		q.ChannelIDs, err = s.Channels.VisibleStreamIDs(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channels"})
+			return
+		}
+	}
+	resp := SearchResponse{Messages: []models.Message{}}
+	if len(q.ChannelIDs) == 0 {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	page, err := services.Chat().SearchMessages(c.Request.Context(), q)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+	if len(page.Messages) > 0 {
+		resp.Messages = page.Messages
+	}
+	if !decorateMessages(c, resp.Messages) {
+		return
+	}
+	if page.Next != "" {
+		resp.NextCursor = &page.Next
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (f *fixture) search(t *testing.T, as models.User, params url.Values) SearchResponse {
+	t.Helper()
+	w := f.do(t, as, http.MethodGet, "/search/messages?"+params.Encode(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("search status = %d, body %s", w.Code, w.Body)
+	}
+	var resp SearchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSearchOnlyReturnsReadableMessages(t *testing.T) {
+	f := newFixture(t)
+	private := f.createChannel(t, f.adminB, "secret")
+	private.Type = models.ChannelPrivate
+	mustSave(t, &private)
+	chanA := f.createChannel(t, f.adminA, "random")
+
+	f.sendMessage(t, f.memberB, f.chanB, "Quarterly report is ready")
+	f.sendMessage(t, f.adminB, private, "quarterly salaries")
+	f.sendMessage(t, f.adminA, chanA, "quarterly numbers in tenant A")
+	deleted := f.sendMessage(t, f.memberB, f.chanB, "old quarterly draft")
+	if w := f.do(t, f.memberB, http.MethodDelete, "/messages/"+deleted.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d", w.Code)
+	}
+
+	got := f.search(t, f.memberB, url.Values{"q": {"QUARTERLY"}})
+	if len(got.Messages) != 1 || got.Messages[0].Text != "Quarterly report is ready" {
+		t.Errorf("member results = %+v", got.Messages)
+	}
+	if got := f.search(t, f.adminB, url.Values{"q": {"quarterly"}}); len(got.Messages) != 2 {
+		t.Errorf("admin (private member) got %d results, want 2", len(got.Messages))
+	}
+	if got := f.search(t, f.adminB, url.Values{"q": {"quarterly"}, "author_id": {f.memberB.ID}}); len(got.Messages) != 1 {
+		t.Errorf("author filter got %d results, want 1", len(got.Messages))
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/search/messages?q=x&channel_id="+private.StreamID, nil); w.Code != http.StatusForbidden {
+		t.Errorf("search in private channel status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/search/messages?q=x&channel_id="+chanA.StreamID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("search in other tenant's channel status = %d, want 404", w.Code)
+	}
+}
+
+func TestSearchHasAttachmentAndPaging(t *testing.T) {
+	f := newFixture(t)
+	w := f.upload(t, f.memberB, f.chanB.StreamID, "shot.png", pngHeader)
+	var att models.Attachment
+	if err := json.Unmarshal(w.Body.Bytes(), &att); err != nil {
+		t.Fatal(err)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "see screenshot", "attachment_ids": []string{att.ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send status = %d, body %s", w.Code, w.Body)
+	}
+	f.sendMessage(t, f.memberB, f.chanB, "no screenshot here")
+
+	got := f.search(t, f.guestB, url.Values{"q": {"screenshot has:attachment"}})
+	if len(got.Messages) != 1 || len(got.Messages[0].Attachments) != 1 {
+		t.Errorf("has:attachment results = %+v", got.Messages)
+	}
+
+	first := f.search(t, f.guestB, url.Values{"q": {"screenshot"}, "limit": {"1"}})
+	if len(first.Messages) != 1 || first.NextCursor == nil {
+		t.Fatalf("first page = %+v", first)
+	}
+	second := f.search(t, f.guestB, url.Values{"q": {"screenshot"}, "limit": {"1"}, "cursor": {*first.NextCursor}})
+	if len(second.Messages) != 1 || second.NextCursor != nil || second.Messages[0].ID == first.Messages[0].ID {
+		t.Errorf("second page = %+v", second)
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+)
+
+// Server serves the API endpoints. Tenants, users, channels, roles, invites,
+// attachments and mentions are read and written through the repositories it
+// is given. Messaging, conversations, channel membership, authentication,
+// permissions and restores still go through the services package and the
+// global db.DB until they get repositories of their own.
+type Server struct {
+	repository.Repos
+}
+
+// NewServer returns a Server backed by repos
+func NewServer(repos repository.Repos) *Server {
+	return &Server{Repos: repos}
+}
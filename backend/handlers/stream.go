@@ -1,26 +1,31 @@
-// handlers/stream.go - Stream Chat token endpoint
+// handlers/stream.go - Chat token and message endpoints
 package handlers
 
 import (
-	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
 	services "github.com/Tabintel/multi-tenant-chat/backend/services"
 	"github.com/gin-gonic/gin"
-	stream_chat "github.com/GetStream/stream-chat-go/v5"
 )
 
-// StreamToken issues a Stream Chat token for the authenticated user
+// StreamToken issues a chat token for the authenticated user
 // @Summary Get Stream Chat token
-// @Description Issues a Stream Chat token for the authenticated user
+// @Description Issues a chat token for the authenticated user from the configured chat provider
 // @Tags stream
 // @Produce json
 // @Success 200 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /stream/token [get]
-func StreamToken(c *gin.Context) {
+func (s *Server) StreamToken(c *gin.Context) {
 	userID := c.GetString("user_id")
-	token, err := services.CreateStreamToken(userID)
+	token, err := services.Chat().CreateToken(userID, time.Now().Add(24*time.Hour))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create Stream token"})
 		return
@@ -29,62 +34,294 @@ func StreamToken(c *gin.Context) {
 }
 
 // SendMessageRequest is the payload for sending a message
+type SendMessageRequest struct {
+	StreamID string `json:"stream_id" binding:"required"` // Stream channel ID
+	Text     string `json:"text"` // required unless attachments are sent
+	ParentID string `json:"parent_id"` // top-level message to reply to
+	// AttachmentIDs are attachments the caller uploaded to the channel and has not sent yet
+	AttachmentIDs []string `json:"attachment_ids"`
+}
+
+// channelForCaller loads the channel with the given Stream ID from the caller's
+// tenant along with the caller's channel role ("" if not a member). Private
+// channels and direct conversations are only accessible to members. On failure it writes the error
+// response and returns false.
+func (s *Server) channelForCaller(c *gin.Context, streamID string) (*models.Channel, models.ChannelRole, bool) {
+	channel, err := s.Channels.GetByStreamID(c.Request.Context(), c.GetString("tenant_id"), streamID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch channel"})
+		}
+		return nil, "", false
+	}
+	role, err := services.ChannelMemberRole(c.Request.Context(), channel.ID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check channel membership"})
+		return nil, "", false
+	}
+	if role == "" && channel.Type.MembersOnly() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of this channel"})
+		return nil, "", false
+	}
+	return channel, role, true
+}
+
+// canPost reports whether the caller, with the given channel role, may post in
+// channel. It writes a 403 or 500 response and returns false otherwise.
+func canPost(c *gin.Context, channel *models.Channel, role models.ChannelRole) bool {
+	if channel.ArchivedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Channel is archived"})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Join this channel before posting"})
+		return false
+	}
+	if channel.Type == models.ChannelAnnouncement {
+		// Only tenant admins/moderators (channel.manage) and channel owners/moderators announce
+		manage, err := hasPermission(c, models.PermChannelManage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+			return false
+		}
+		if !manage && !role.CanManage() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and moderators can post announcements"})
+			return false
+		}
+		return true
+	}
+	canSend, err := hasPermission(c, models.PermMessageSend)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return false
+	}
+	// Guests are read-only unless the channel explicitly allows guest posting
+	if !canSend && !(c.GetString("user_role") == string(models.RoleGuest) && channel.AllowGuestPosting) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to post in this channel"})
+		return false
+	}
+	return true
+}
+
+// SendMessage sends a message to a channel
 // @Summary Send a message to a Stream channel
-// @Description Sends a message to a Stream channel as the authenticated user
+// @Description Sends a message, or a reply when parent_id is set, to a Stream channel of the caller's tenant, optionally with attachments uploaded through POST /attachments. @name mentions of users of the tenant (by email, email local part or name without spaces) and @channel/@here are recorded and notified. The caller must be a channel member with message.send; guests may post where the channel allows guest posting, and only admins/moderators may post in announcement channels.
 // @Tags stream
 // @Accept json
 // @Produce json
 // @Param message body SendMessageRequest true "Message info"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /messages [post]
-type SendMessageRequest struct {
-	StreamID string `json:"stream_id" binding:"required"` // Stream channel ID
-	Text     string `json:"text" binding:"required"`
-}
-
-func SendMessage(c *gin.Context) {
+func (s *Server) SendMessage(c *gin.Context) {
 	var req SendMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	req.AttachmentIDs = uniqueStrings(req.AttachmentIDs)
+	if req.Text == "" && len(req.AttachmentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A message needs text or attachments"})
+		return
+	}
+	channel, role, ok := s.channelForCaller(c, req.StreamID)
+	if !ok || !canPost(c, channel, role) {
+		return
+	}
+	ctx := c.Request.Context()
 	userID := c.GetString("user_id")
-	client := services.GetStreamClient()
-	channel := client.Channel("messaging", req.StreamID)
-	msg := &stream_chat.Message{
-		Text: req.Text,
-		User: &stream_chat.User{ID: userID},
+	var attachments []models.Attachment
+	if len(req.AttachmentIDs) > 0 {
+		var err error
+		attachments, err = services.PendingAttachments(ctx, req.AttachmentIDs, userID, req.StreamID)
+		if errors.Is(err, services.ErrInvalidAttachments) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Attachments must be your unsent uploads to this channel"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch attachments"})
+			return
+		}
+	}
+	var parent *models.Message
+	if req.ParentID != "" {
+		var err error
+		parent, err = services.Chat().GetMessage(ctx, req.ParentID)
+		if errors.Is(err, services.ErrMessageNotFound) || (err == nil && parent.ChannelID != req.StreamID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent message not found in this channel"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch parent message"})
+			return
+		}
+		if parent.ParentID != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reply to a reply"})
+			return
+		}
+	}
+	mentions, err := services.ResolveMentions(ctx, channel, userID, req.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not resolve mentions"})
+		return
+	}
+	msg, err := services.Chat().SendMessage(ctx, req.StreamID, services.MessageInput{
+		UserID:           userID,
+		Text:             req.Text,
+		ParentID:         req.ParentID,
+		Attachments:      attachments,
+		MentionedUserIDs: services.MentionedUserIDs(mentions),
+	})
+	if errors.Is(err, services.ErrChannelNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+	if errors.Is(err, services.ErrChannelFrozen) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Channel is archived"})
+		return
 	}
-	_, err := channel.SendMessage(context.Background(), msg, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message: " + err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "Message sent"})
+	// The message is delivered at this point and its ID only exists now, so
+	// failing the request would make clients resend it. Bookkeeping errors are
+	// logged instead.
+	if err := services.LinkAttachments(ctx, msg, attachments); err != nil {
+		log.Printf("link attachments of message %s: %v", msg.ID, err)
+	}
+	if err := services.RecordMentions(ctx, msg, mentions); err != nil {
+		log.Printf("record mentions of message %s: %v", msg.ID, err)
+	}
+	if parent != nil {
+		if err := services.AddThreadParticipants(ctx, parent.ID, parent.UserID, userID); err != nil {
+			log.Printf("track participants of thread %s: %v", parent.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Message sent", "message": msg})
+}
+
+// MessagesResponse is a page of messages, oldest first. PrevCursor is passed
+// as before_id to load older messages and NextCursor as after_id to load newer
+// ones; each is null when there is nothing more in that direction.
+type MessagesResponse struct {
+	Messages   []models.Message `json:"messages"`
+	PrevCursor *string          `json:"prev_cursor"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+// messageQuery parses the pagination parameters of GetMessages.
+// On failure it writes a 400 response and returns false.
+func messageQuery(c *gin.Context) (services.MessageQuery, bool) {
+	var q services.MessageQuery
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > services.MaxMessageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxMessageLimit)})
+			return q, false
+		}
+		q.Limit = limit
+	}
+	q.BeforeID = c.Query("before_id")
+	q.AfterID = c.Query("after_id")
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"before", &q.Before}, {"after", &q.After}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be an RFC 3339 timestamp"})
+			return q, false
+		}
+		*p.dst = &t
+	}
+	return q, true
 }
 
-// GetMessages fetches messages from a Stream channel
+// GetMessages fetches a page of messages from a channel
 // @Summary Get messages from a Stream channel
-// @Description Retrieves the most recent messages from a Stream channel
+// @Description Retrieves a page of messages, oldest first, from a Stream channel of the caller's tenant; private channels require membership. Without cursors the newest messages are returned. Use prev_cursor as before_id for older history and next_cursor as after_id for newer messages. Each message carries its reaction counts per emoji, whether the caller reacted, and its attachments with signed download URLs.
 // @Tags stream
 // @Produce json
-// @Param stream_id path string true "Stream channel ID"
-// @Success 200 {object} map[string]interface{}
+// @Param id path string true "Stream channel ID"
+// @Param limit query int false "Page size (default 25, max 100)"
+// @Param before_id query string false "Only messages older than this message ID"
+// @Param after_id query string false "Only messages newer than this message ID"
+// @Param before query string false "Only messages created before this RFC 3339 timestamp"
+// @Param after query string false "Only messages created after this RFC 3339 timestamp"
+// @Success 200 {object} MessagesResponse
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
-// @Router /messages/{stream_id} [get]
-func GetMessages(c *gin.Context) {
-	streamID := c.Param("stream_id")
-	client := services.GetStreamClient()
-	channel := client.Channel("messaging", streamID)
-	resp, err := channel.Query(context.Background(), nil)
+// @Router /messages/{id} [get]
+func (s *Server) GetMessages(c *gin.Context) {
+	q, ok := messageQuery(c)
+	if !ok {
+		return
+	}
+	// The path parameter is named id because it shares the route tree with /messages/:id/...
+	streamID := c.Param("id")
+	if _, _, ok := s.channelForCaller(c, streamID); !ok {
+		return
+	}
+	page, err := services.Chat().GetMessages(c.Request.Context(), streamID, q)
+	if errors.Is(err, services.ErrChannelNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown message cursor"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages: " + err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"messages": resp.Messages})
+	if !decorateMessages(c, page.Messages) {
+		return
+	}
+	c.JSON(http.StatusOK, newMessagesResponse(page))
+}
+
+// decorateMessages adds the reaction counts and attachments to messages.
+// On failure it writes a 500 response and returns false.
+func decorateMessages(c *gin.Context, messages []models.Message) bool {
+	ctx := c.Request.Context()
+	if err := services.AttachReactions(ctx, messages, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reactions"})
+		return false
+	}
+	if err := services.AttachAttachments(ctx, messages); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch attachments"})
+		return false
+	}
+	return true
+}
+
+func newMessagesResponse(page *services.MessagePage) MessagesResponse {
+	resp := MessagesResponse{Messages: page.Messages}
+	if resp.Messages == nil {
+		resp.Messages = []models.Message{}
+	}
+	if n := len(page.Messages); n > 0 {
+		if page.HasOlder {
+			resp.PrevCursor = &page.Messages[0].ID
+		}
+		if page.HasNewer {
+			resp.NextCursor = &page.Messages[n-1].ID
+		}
+	}
+	return resp
 }
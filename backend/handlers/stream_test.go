@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendMessageCrossTenantIsNotFound(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "hi"})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	w = f.do(t, f.adminA, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GET status = %d, want 404", w.Code)
+	}
+}
+
+func TestSendMessageRequiresMembership(t *testing.T) {
+	f := newFixture(t)
+	private := f.createChannel(t, f.adminB, "private")
+	private.Type = models.ChannelPrivate
+	mustSave(t, &private)
+	w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": private.StreamID, "text": "hi"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodGet, "/messages/"+private.StreamID, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("GET status = %d, want 403", w.Code)
+	}
+}
+
+func TestMemberCanSendAndRead(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "hi"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestGuestIsReadOnlyUnlessAllowed(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.guestB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "hi"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.guestB, http.MethodGet, "/messages/"+f.chanB.StreamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, body %s", w.Code, w.Body)
+	}
+
+	f.chanB.AllowGuestPosting = true
+	mustSave(t, &f.chanB)
+	w = f.do(t, f.guestB, http.MethodPost, "/messages", gin.H{"stream_id": f.chanB.StreamID, "text": "hi"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status with guest posting = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestPublicChannelRequiresJoinToPost(t *testing.T) {
+	f := newFixture(t)
+	open := f.createChannel(t, f.adminB, "open")
+	w := f.do(t, f.memberB, http.MethodGet, "/messages/"+open.StreamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": open.StreamID, "text": "hi"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status before join = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/channels/"+open.ID+"/join", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("join status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": open.StreamID, "text": "hi"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status after join = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestPrivateChannelIsHiddenAndInviteOnly(t *testing.T) {
+	f := newFixture(t)
+	private := f.createChannel(t, f.adminB, "secret")
+	private.Type = models.ChannelPrivate
+	mustSave(t, &private)
+
+	w := f.do(t, f.memberB, http.MethodGet, "/channels", nil)
+	var channels db.Page[models.Channel]
+	if err := json.Unmarshal(w.Body.Bytes(), &channels); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range channels.Items {
+		if ch.ID == private.ID {
+			t.Errorf("private channel listed to a non-member")
+		}
+	}
+	w = f.do(t, f.memberB, http.MethodPost, "/channels/"+private.ID+"/join", nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("join status = %d, want 403", w.Code)
+	}
+}
+
+func TestAnnouncementChannelOnlyModeratorsPost(t *testing.T) {
+	f := newFixture(t)
+	news := f.createChannel(t, f.adminB, "news", f.memberB)
+	news.Type = models.ChannelAnnouncement
+	mustSave(t, &news)
+	w := f.do(t, f.memberB, http.MethodPost, "/messages", gin.H{"stream_id": news.StreamID, "text": "hi"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("member status = %d, want 403", w.Code)
+	}
+	w = f.do(t, f.adminB, http.MethodPost, "/messages", gin.H{"stream_id": news.StreamID, "text": "hello all"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin status = %d, body %s", w.Code, w.Body)
+	}
+	w = f.do(t, f.memberB, http.MethodGet, "/messages/"+news.StreamID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("member GET status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestGetMessagesCursorPagination(t *testing.T) {
+	f := newFixture(t)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		msg := models.Message{ChannelID: f.chanB.StreamID, UserID: f.adminB.ID, Text: "m", CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		mustCreate(t, &msg)
+		ids = append(ids, msg.ID)
+	}
+	page := func(query string) MessagesResponse {
+		t.Helper()
+		w := f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", query, w.Code, w.Body)
+		}
+		var resp MessagesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	check := func(resp MessagesResponse, want ...string) {
+		t.Helper()
+		if len(resp.Messages) != len(want) {
+			t.Fatalf("got %d messages, want %d", len(resp.Messages), len(want))
+		}
+		for i, m := range resp.Messages {
+			if m.ID != want[i] {
+				t.Errorf("message %d = %s, want %s", i, m.ID, want[i])
+			}
+		}
+	}
+
+	newest := page("?limit=2")
+	check(newest, ids[3], ids[4])
+	if newest.PrevCursor == nil || *newest.PrevCursor != ids[3] || newest.NextCursor != nil {
+		t.Errorf("newest page cursors = %v, %v", newest.PrevCursor, newest.NextCursor)
+	}
+	older := page("?limit=2&before_id=" + *newest.PrevCursor)
+	check(older, ids[1], ids[2])
+	if older.NextCursor == nil || *older.NextCursor != ids[2] {
+		t.Errorf("older page has no next cursor")
+	}
+	newer := page("?limit=5&after_id=" + ids[2])
+	check(newer, ids[3], ids[4])
+	if newer.NextCursor != nil {
+		t.Errorf("last page has a next cursor")
+	}
+	byTime := page("?after=" + base.Add(90*time.Second).Format(time.RFC3339))
+	check(byTime, ids[2], ids[3], ids[4])
+
+	w := f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID+"?limit=1000", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("oversized limit status = %d, want 400", w.Code)
+	}
+	w = f.do(t, f.memberB, http.MethodGet, "/messages/"+f.chanB.StreamID+"?before_id=nope", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown cursor status = %d, want 400", w.Code)
+	}
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"github.com/gin-gonic/gin"
 	"github.com/Tabintel/multi-tenant-chat/backend/db"
 	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
 	"github.com/Tabintel/multi-tenant-chat/backend/services"
 )
 
@@ -22,112 +25,277 @@ import (
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /tenants [post]
-func CreateTenant(c *gin.Context) {
+func (s *Server) CreateTenant(c *gin.Context) {
 	var req models.Tenant
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	if err := db.DB.Create(&req).Error; err != nil {
+	if err := s.Tenants.Create(c.Request.Context(), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create tenant"})
 		return
 	}
 	c.JSON(http.StatusCreated, req)
 }
 
-// ListTenants lists all tenants (Admin only)
+// tenantListSpec is the sorting and search supported by ListTenants
+var tenantListSpec = db.ListSpec{
+	Sorts:         map[string]db.SortField{"name": {Column: "name"}},
+	DefaultSort:   "name",
+	SearchColumns: []string{"name"},
+}
+
+// ListTenants lists tenants a page at a time
 // @Summary List tenants
-// @Description Lists all tenants (organizations)
+// @Description Lists tenants (organizations) a page at a time (needs tenant.create)
 // @Tags tenants
 // @Produce json
-// @Success 200 {array} models.Tenant
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name or -name"
+// @Param q query string false "Search by name"
+// @Success 200 {object} db.Page[models.Tenant]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /tenants [get]
-func ListTenants(c *gin.Context) {
-	var tenants []models.Tenant
-	if err := db.DB.Find(&tenants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch tenants"})
+func (s *Server) ListTenants(c *gin.Context) {
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.Tenant], error) {
+		return s.Tenants.List(c.Request.Context(), tenantListSpec, opts)
+	}, "tenants")
+}
+
+// DeleteTenant deletes the caller's tenant (Admin only)
+// @Summary Delete tenant
+// @Description Soft-deletes the caller's own tenant: none of its users can sign in until it is restored within the grace period (Admin only)
+// @Tags tenants
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tenants/{id} [delete]
+func (s *Server) DeleteTenant(c *gin.Context) {
+	// Tenants can only delete themselves; other tenants are not found
+	if c.Param("id") != c.GetString("tenant_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
 		return
 	}
-	c.JSON(http.StatusOK, tenants)
+	tenant, err := s.Tenants.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+	if err := services.DeleteTenant(c.Request.Context(), tenant); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete tenant"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// ListDeletedTenants lists the caller's tenants that can still be restored
+// @Summary List deleted tenants
+// @Description Lists the deleted tenants the caller is a member of that are still within the grace period (requires tenant.create)
+// @Tags tenants
+// @Produce json
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "deleted_at or name; prefix with - for descending (default -deleted_at)"
+// @Param q query string false "Search by name"
+// @Success 200 {object} db.Page[models.Tenant]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tenants/deleted [get]
+func (s *Server) ListDeletedTenants(c *gin.Context) {
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.Tenant], error) {
+		return s.Tenants.ListDeleted(c.Request.Context(), c.GetString("user_id"), services.RestorableSince(), deletedListSpec, opts)
+	}, "deleted tenants")
+}
+
+// RestoreTenant restores a deleted tenant the caller manages
+// @Summary Restore tenant
+// @Description Restores a deleted tenant within the grace period so its users can sign in again. The caller must be a member of that tenant with tenant.manage there; other tenants are not found.
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} models.Tenant
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tenants/{id}/restore [post]
+func (s *Server) RestoreTenant(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenant, err := s.Tenants.GetDeleted(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted tenant not found"})
+		return
+	}
+	// Only the deleted tenant's own managers may restore it
+	member, err := s.Users.Member(ctx, tenant.ID, c.GetString("user_id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted tenant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch membership"})
+		return
+	}
+	allowed, err := services.HasPermission(ctx, tenant.ID, member.Role, models.PermTenantManage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if err := services.RestoreTenant(ctx, tenant); err != nil {
+		respondRestoreError(c, err, "tenant")
+		return
+	}
+	c.JSON(http.StatusOK, tenant)
 }
 
 // --- USER HANDLERS ---
 
-// CreateUser creates a new user in a tenant
+// CreateUserRequest is the payload for creating a user in the caller's tenant
+type CreateUserRequest struct {
+	Name     string      `json:"name" binding:"required"`
+	Email    string      `json:"email" binding:"required"`
+	Password string      `json:"password" binding:"required"`
+	Role     models.Role `json:"role"` // built-in or custom role, MEMBER if empty
+}
+
+// UpdateUserRequest changes a user's role in the caller's tenant. The
+// account's name and email are shared with its other tenants, so only its
+// owner changes them (see UpdateAccount).
+type UpdateUserRequest struct {
+	Role models.Role `json:"role" binding:"required"` // built-in or custom role
+}
+
+// UpdateAccountRequest holds the caller's account fields to change; empty
+// fields are left as is
+type UpdateAccountRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// CreateUser creates a new user in the caller's tenant
 // @Summary Create user
-// @Description Creates a new user in a tenant
+// @Description Creates a new user in the caller's tenant with a built-in or custom role; only admins may create admins and nobody may grant permissions they lack
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body models.User true "User info"
+// @Param user body CreateUserRequest true "User info"
 // @Success 201 {object} models.User
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /users [post]
-func CreateUser(c *gin.Context) {
-	var req models.User
+func (s *Server) CreateUser(c *gin.Context) {
+	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if !checkAssignableRole(c, req.Role) {
+		return
+	}
+	// Existing accounts join other organizations through invites, which
+	// need their own password
+	taken, err := s.Users.EmailTaken(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists; invite it instead"})
+		return
+	}
 	hash, err := services.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
 		return
 	}
-	req.Password = string(hash)
-	if err := db.DB.Create(&req).Error; err != nil {
+	user := models.User{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: string(hash),
+		Role:     req.Role,
+		TenantID: c.GetString("tenant_id"),
+	}
+	if err := s.Users.Create(c.Request.Context(), &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
 		return
 	}
-	if err := services.CreateStreamUser(req); err != nil {
+	if err := services.Chat().UpsertUser(c.Request.Context(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stream user creation failed"})
 		return
 	}
-	c.JSON(http.StatusCreated, req)
+	c.JSON(http.StatusCreated, user)
+}
+
+// userListSpec is the sorting and search supported by ListUsers
+var userListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"name":  {Column: "name"},
+		"email": {Column: "email"},
+		"role":  {Column: "role"},
+	},
+	DefaultSort:   "name",
+	SearchColumns: []string{"name", "email"},
 }
 
-// ListUsers lists all users for a tenant
+// ListUsers lists the users of the caller's tenant a page at a time
 // @Summary List users
-// @Description Lists all users for a tenant
+// @Description Lists the users of the caller's tenant a page at a time, optionally filtered by role and searched by name or email
 // @Tags users
 // @Produce json
-// @Success 200 {array} models.User
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name, email or role; prefix with - for descending"
+// @Param q query string false "Search by name or email"
+// @Param role query string false "Only users with this role"
+// @Success 200 {object} db.Page[models.User]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /users [get]
-func ListUsers(c *gin.Context) {
-	tenantID, _ := c.Get("tenant_id")
-	var users []models.User
-	if err := db.DB.Where("tenant_id = ?", tenantID).Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch users"})
-		return
-	}
-	c.JSON(http.StatusOK, users)
+func (s *Server) ListUsers(c *gin.Context) {
+	filter := repository.UserFilter{Role: models.Role(c.Query("role"))}
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.User], error) {
+		return s.Users.List(c.Request.Context(), c.GetString("tenant_id"), filter, userListSpec, opts)
+	}, "users")
 }
 
-// UpdateUser updates a user's info (Admin/Moderator only)
+// UpdateUser changes a user's role (Admin/Moderator only)
 // @Summary Update user
-// @Description Updates a user's info (Admin/Moderator only)
+// @Description Changes a user's (custom) role in the caller's tenant (Admin/Moderator only; only admins may modify admins). Name and email belong to the account and are changed by its owner through PUT /me.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID"
-// @Param user body models.User true "User info"
+// @Param user body UpdateUserRequest true "User info"
 // @Success 200 {object} models.User
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /users/{id} [put]
-func UpdateUser(c *gin.Context) {
-	userID := c.Param("id")
-	var req models.User
-	var user models.User
-	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+func (s *Server) UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	user, err := s.Users.Member(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -135,36 +303,183 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	// The caller must outrank both the user's current and new role
+	allowed, err := canAssignRole(c, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if !checkAssignableRole(c, req.Role) {
+		return
+	}
+	if req.Role == user.Role {
+		c.JSON(http.StatusOK, user)
+		return
+	}
+	user.Role = req.Role
+	if err := s.Users.UpdateRole(c.Request.Context(), user.TenantID, user.ID, user.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+		return
+	}
+	// Tokens carry the role, so a role change must invalidate them
+	if err := services.RevokeUserTokens(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not revoke user tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateAccount changes the caller's own name or email
+// @Summary Update my account
+// @Description Changes the caller's name or email. Both are shared by every organization the account belongs to.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param account body UpdateAccountRequest true "Account info"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /me [put]
+func (s *Server) UpdateAccount(c *gin.Context) {
+	var req UpdateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := s.Users.Member(ctx, c.GetString("tenant_id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch account"})
+		return
+	}
 	if req.Name != "" {
 		user.Name = req.Name
 	}
-	if req.Role != "" {
-		user.Role = req.Role
+	if req.Email != "" && !strings.EqualFold(req.Email, user.Email) {
+		taken, err := s.Users.EmailTaken(ctx, req.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update account"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
 	}
 	if req.Email != "" {
 		user.Email = req.Email
 	}
-	if err := db.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+	err = s.Users.UpdateAccount(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update account"})
 		return
 	}
 	c.JSON(http.StatusOK, user)
 }
 
-// DeleteUser removes a user (Admin only)
+// DeleteUser removes a user from the caller's tenant (Admin only)
 // @Summary Delete user
-// @Description Removes a user (Admin only)
+// @Description Removes a user from the caller's tenant (Admin only): their sessions are revoked and, if it was their last organization, the account and chat user are deactivated. Their messages keep their author. Removed users can be restored during the grace period.
 // @Tags users
 // @Param id path string true "User ID"
 // @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /users/{id} [delete]
-func DeleteUser(c *gin.Context) {
-	userID := c.Param("id")
-	if err := db.DB.Delete(&models.User{}, "id = ?", userID).Error; err != nil {
+func (s *Server) DeleteUser(c *gin.Context) {
+	user, err := s.Users.Member(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := services.RemoveUser(c.Request.Context(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete user"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"deleted": true})
 }
+
+// removedUserListSpec is the sorting and search supported by ListDeletedUsers
+var removedUserListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"name":       {Column: "name"},
+		"removed_at": {Column: "removed_at", Time: true},
+	},
+	DefaultSort:   "-removed_at",
+	SearchColumns: []string{"name", "email"},
+}
+
+// deletedListSpec is the sorting and search supported by ListDeletedTenants
+var deletedListSpec = db.ListSpec{
+	Sorts: map[string]db.SortField{
+		"name":       {Column: "name"},
+		"deleted_at": {Column: "deleted_at", Time: true},
+	},
+	DefaultSort:   "-deleted_at",
+	SearchColumns: []string{"name"},
+}
+
+// ListDeletedUsers lists the users removed from the caller's tenant that can still be restored
+// @Summary List deleted users
+// @Description Lists the users removed from the caller's tenant that are still within the grace period (Admin only)
+// @Tags users
+// @Produce json
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "removed_at or name; prefix with - for descending (default -removed_at)"
+// @Param q query string false "Search by name or email"
+// @Success 200 {object} db.Page[models.User]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /users/deleted [get]
+func (s *Server) ListDeletedUsers(c *gin.Context) {
+	respondList(c, func(opts db.ListOptions) (*db.Page[models.User], error) {
+		return s.Users.ListRemoved(c.Request.Context(), c.GetString("tenant_id"), services.RestorableSince(), removedUserListSpec, opts)
+	}, "deleted users")
+}
+
+// RestoreUser reactivates a deleted user (Admin only)
+// @Summary Restore user
+// @Description Restores a deleted user of the caller's tenant within the grace period, reactivating their chat user (Admin only)
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /users/{id}/restore [post]
+func (s *Server) RestoreUser(c *gin.Context) {
+	user, err := s.Users.RemovedMember(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+		return
+	}
+	if err := services.RestoreUser(c.Request.Context(), user); err != nil {
+		respondRestoreError(c, err, "user")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// respondRestoreError writes the response for a failed restore of what
+func respondRestoreError(c *gin.Context, err error, what string) {
+	if errors.Is(err, services.ErrGracePeriodExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": "The " + what + " was deleted too long ago to be restored"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not restore " + what})
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/repository"
+	"github.com/gin-gonic/gin"
+)
+
+func TestListUsersIsTenantScoped(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodGet, "/users", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var users db.Page[models.User]
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Items) != 2 {
+		t.Fatalf("got %d users, want the 2 of tenant A", len(users.Items))
+	}
+	for _, u := range users.Items {
+		if u.TenantID != f.tenantA.ID {
+			t.Errorf("user %s of tenant %s leaked into tenant A listing", u.Email, u.TenantID)
+		}
+	}
+}
+
+func TestCreateUserUsesCallerTenant(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodPost, "/users", gin.H{
+		"name": "New", "email": "new@a.test", "password": "pw",
+		"tenant_id": f.tenantB.ID, "TenantID": f.tenantB.ID,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var created models.User
+	if err := db.TenantUsers(context.Background(), f.tenantA.ID, false).First(&created, "email = ?", "new@a.test").Error; err != nil {
+		t.Fatal(err)
+	}
+	if created.TenantID != f.tenantA.ID {
+		t.Errorf("user created in tenant %s, want caller's tenant %s", created.TenantID, f.tenantA.ID)
+	}
+	if created.Role != models.RoleMember {
+		t.Errorf("role = %s, want default %s", created.Role, models.RoleMember)
+	}
+}
+
+func TestModeratorCannotCreateAdmin(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.modA, http.MethodPost, "/users", gin.H{
+		"name": "Boss", "email": "boss@a.test", "password": "pw", "role": "ADMIN",
+	})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestUpdateUserCrossTenantIsNotFound(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.modA, http.MethodPut, "/users/"+f.memberB.ID, gin.H{"name": "Hijacked", "role": "MODERATOR"})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	var user models.User
+	if err := db.TenantUsers(context.Background(), f.tenantB.ID, false).First(&user, "id = ?", f.memberB.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Member B" || user.Role != models.RoleMember {
+		t.Errorf("cross-tenant update modified user: %+v", user)
+	}
+}
+
+func TestUpdateUserSameTenant(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodPut, "/users/"+f.memberB.ID, gin.H{"role": "MODERATOR"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestUpdateUserCannotChangeAccount(t *testing.T) {
+	f := newFixture(t)
+	// Member B's account also belongs to tenant A, whose admin must not take it over
+	mustCreate(t, &models.TenantMember{TenantID: f.tenantA.ID, UserID: f.memberB.ID, Role: models.RoleMember})
+	body := gin.H{"role": "MEMBER", "name": "Hijacked", "email": "attacker@a.test"}
+	if w := f.do(t, f.adminA, http.MethodPut, "/users/"+f.memberB.ID, body); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", f.memberB.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != f.memberB.Email || user.Name != f.memberB.Name {
+		t.Errorf("tenant admin changed the account: %+v", user)
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.memberB, http.MethodPut, "/me", gin.H{"name": "Member Bee", "email": "bee@b.test"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", f.memberB.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Member Bee" || user.Email != "bee@b.test" {
+		t.Errorf("account = %+v", user)
+	}
+	if w := f.do(t, f.memberB, http.MethodPut, "/me", gin.H{"email": "ADMIN@a.test"}); w.Code != http.StatusConflict {
+		t.Errorf("email of another account status = %d, want 409", w.Code)
+	}
+}
+
+func TestModeratorCannotModifyAdmin(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.modA, http.MethodPut, "/users/"+f.adminA.ID, gin.H{"role": "GUEST"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestDeleteUserCrossTenantIsNotFound(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodDelete, "/users/"+f.memberB.ID, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	var count int64
+	db.DB.Model(&models.User{}).Where("id = ?", f.memberB.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("cross-tenant delete removed the user")
+	}
+}
+
+func TestDeleteUserDeactivatesAndRestores(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminB, http.MethodDelete, "/users/"+f.memberB.ID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	// The row and its memberships stay, but the user can no longer authenticate
+	var count int64
+	db.DB.Model(&models.ChannelMember{}).Where("user_id = ?", f.memberB.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("memberships = %d, want 1", count)
+	}
+	// Deletion revoked the user's tokens, so pick up the new token version
+	db.DB.Unscoped().First(&f.memberB, "id = ?", f.memberB.ID)
+	if w := f.do(t, f.memberB, http.MethodGet, "/users", nil); !strings.Contains(w.Body.String(), "deactivated") {
+		t.Errorf("deleted user got %d: %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.adminB, http.MethodGet, "/users?q=member", nil); !strings.Contains(w.Body.String(), `"total":0`) {
+		t.Errorf("deleted user still listed: %s", w.Body)
+	}
+
+	w = f.do(t, f.adminB, http.MethodGet, "/users/deleted", nil)
+	var page db.Page[models.User]
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.Items[0].ID != f.memberB.ID {
+		t.Fatalf("deleted users = %s", w.Body)
+	}
+	if w := f.do(t, f.adminA, http.MethodPost, "/users/"+f.memberB.ID+"/restore", nil); w.Code != http.StatusNotFound {
+		t.Errorf("cross-tenant restore status = %d, want 404", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodPost, "/users/"+f.memberB.ID+"/restore", nil); w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/users", nil); w.Code != http.StatusOK {
+		t.Errorf("restored user status = %d, want 200", w.Code)
+	}
+}
+
+func TestRestoreAfterGracePeriodIsGone(t *testing.T) {
+	f := newFixture(t)
+	if w := f.do(t, f.adminB, http.MethodDelete, "/users/"+f.memberB.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	t.Setenv("DELETE_GRACE_PERIOD", "1ns")
+	if w := f.do(t, f.adminB, http.MethodPost, "/users/"+f.memberB.ID+"/restore", nil); w.Code != http.StatusGone {
+		t.Errorf("status = %d, want 410", w.Code)
+	}
+}
+
+func TestDeleteTenantBlocksItsUsers(t *testing.T) {
+	f := newFixture(t)
+	// Admin A also administers tenant B, so they can still sign in to restore it
+	mustCreate(t, &models.TenantMember{TenantID: f.tenantB.ID, UserID: f.adminA.ID, Role: models.RoleAdmin})
+	if w := f.do(t, f.adminA, http.MethodDelete, "/tenants/"+f.tenantB.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant delete status = %d, want 404", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodDelete, "/tenants/"+f.tenantB.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/users", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("user of deleted tenant status = %d, want 401", w.Code)
+	}
+	if w := f.do(t, f.adminA, http.MethodGet, "/tenants/deleted", nil); !strings.Contains(w.Body.String(), f.tenantB.ID) {
+		t.Errorf("deleted tenants = %s", w.Body)
+	}
+	if w := f.do(t, f.adminA, http.MethodPost, "/tenants/"+f.tenantB.ID+"/restore", nil); w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/users", nil); w.Code != http.StatusOK {
+		t.Errorf("user of restored tenant status = %d, want 200", w.Code)
+	}
+}
+
+func TestRestoreTenantIsScopedToItsMembers(t *testing.T) {
+	f := newFixture(t)
+	if w := f.do(t, f.adminA, http.MethodDelete, "/tenants/"+f.tenantA.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if w := f.do(t, f.adminB, http.MethodGet, "/tenants/deleted", nil); strings.Contains(w.Body.String(), f.tenantA.ID) {
+		t.Errorf("admin B sees tenant A among deleted tenants: %s", w.Body)
+	}
+	if w := f.do(t, f.adminB, http.MethodPost, "/tenants/"+f.tenantA.ID+"/restore", nil); w.Code != http.StatusNotFound {
+		t.Errorf("cross-tenant restore status = %d, want 404", w.Code)
+	}
+
+	// Being a plain member of tenant A is not enough to restore it
+	mustCreate(t, &models.TenantMember{TenantID: f.tenantA.ID, UserID: f.adminB.ID, Role: models.RoleMember})
+	if w := f.do(t, f.adminB, http.MethodPost, "/tenants/"+f.tenantA.ID+"/restore", nil); w.Code != http.StatusForbidden {
+		t.Errorf("member restore status = %d, want 403", w.Code)
+	}
+	if _, err := repository.NewGorm(db.DB).Tenants.GetDeleted(context.Background(), f.tenantA.ID); err != nil {
+		t.Errorf("tenant A was restored: %v", err)
+	}
+}
+
+func TestListChannelsIsTenantScoped(t *testing.T) {
+	f := newFixture(t)
+	w := f.do(t, f.adminA, http.MethodGet, "/channels", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var channels db.Page[models.Channel]
+	if err := json.Unmarshal(w.Body.Bytes(), &channels); err != nil {
+		t.Fatal(err)
+	}
+	if len(channels.Items) != 0 {
+		t.Errorf("tenant A sees %d channels of tenant B", len(channels.Items))
+	}
+}
+
+func TestListUsersPaginatesAndFilters(t *testing.T) {
+	f := newFixture(t)
+	for _, name := range []string{"Carol", "Dave", "Erin"} {
+		mustCreate(t, &models.User{Name: name, Email: name + "@b.test", Password: "x", Role: models.RoleMember, TenantID: f.tenantB.ID})
+	}
+	var seen []string
+	cursor := ""
+	for {
+		w := f.do(t, f.adminB, http.MethodGet, "/users?limit=2&sort=-name&cursor="+cursor, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var page db.Page[models.User]
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 6 {
+			t.Errorf("total = %d, want 6", page.Total)
+		}
+		for _, u := range page.Items {
+			seen = append(seen, u.Name)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	want := []string{"Member B", "Guest B", "Erin", "Dave", "Carol", "Admin B"}
+	if len(seen) != len(want) {
+		t.Fatalf("paged through %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("item %d = %s, want %s", i, seen[i], want[i])
+		}
+	}
+
+	w := f.do(t, f.adminB, http.MethodGet, "/users?role=MEMBER&q=er", nil)
+	var page db.Page[models.User]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	// Member B and Erin match "er"; Guest B is not a member
+	if page.Total != 2 {
+		t.Errorf("filtered total = %d, want 2", page.Total)
+	}
+	w = f.do(t, f.adminB, http.MethodGet, "/users?sort=password", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported sort status = %d, want 400", w.Code)
+	}
+}
+
+func TestListTenantsNeedsTenantCreate(t *testing.T) {
+	f := newFixture(t)
+	if w := f.send(t, "", http.MethodGet, "/tenants?q=Tenant", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous status = %d, want 401", w.Code)
+	}
+	if w := f.do(t, f.memberB, http.MethodGet, "/tenants", nil); w.Code != http.StatusForbidden {
+		t.Errorf("member status = %d, want 403", w.Code)
+	}
+	if w := f.do(t, f.adminB, http.MethodGet, "/tenants", nil); w.Code != http.StatusOK {
+		t.Errorf("admin status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestListTenantsFromMemoryRepos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemory().Repos()
+	for _, name := range []string{"Beta", "Alpha"} {
+		if err := repos.Tenants.Create(context.Background(), &models.Tenant{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := gin.New()
+	r.GET("/tenants", NewServer(repos).ListTenants)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tenants?limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var page db.Page[models.Tenant]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].Name != "Alpha" || page.NextCursor == nil {
+		t.Errorf("page = %+v", page)
+	}
+}
@@ -2,15 +2,14 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
+// JWTAuth validates the access token, including revocation, and stores its claims in the context
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
@@ -22,14 +21,19 @@ func JWTAuth() gin.HandlerFunc {
 		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
 			tokenString = tokenString[7:]
 		}
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := services.ParseAccessToken(c.Request.Context(), tokenString)
+		if errors.Is(err, services.ErrInvalidToken) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
-		claims := token.Claims.(jwt.MapClaims)
+		if errors.Is(err, services.ErrUserDeactivated) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not validate token"})
+			return
+		}
 		c.Set("user_id", claims["user_id"])
 		if role, ok := claims["role"]; ok {
 			c.Set("user_role", role)
@@ -37,6 +41,10 @@ func JWTAuth() gin.HandlerFunc {
 		if tenantID, ok := claims["tenant_id"]; ok {
 			c.Set("tenant_id", tenantID)
 		}
+		c.Set("jti", claims["jti"])
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("token_exp", exp.Time)
+		}
 		c.Next()
 	}
 }
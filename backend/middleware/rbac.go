@@ -3,22 +3,29 @@ package middleware
 import (
 	"net/http"
 	"github.com/gin-gonic/gin"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/Tabintel/multi-tenant-chat/backend/services"
 )
 
-// RequireRole is middleware to enforce RBAC on endpoints
-func RequireRole(roles ...string) gin.HandlerFunc {
+// RequirePermission is middleware that allows the request only if the
+// caller's role has perm in their tenant (defaults plus tenant overrides)
+func RequirePermission(perm models.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
 		if !exists {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing user role"})
 			return
 		}
-		for _, role := range roles {
-			if userRole == role {
-				c.Next()
-				return
-			}
+		role, _ := userRole.(string)
+		ok, err := services.HasPermission(c.Request.Context(), c.GetString("tenant_id"), models.Role(role), perm)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not check permissions"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
 		}
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Next()
 	}
 }
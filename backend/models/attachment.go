@@ -0,0 +1,57 @@
+// models/attachment.go - Uploaded files referenced by messages
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attachment is a file uploaded to a channel. It is pending until a message
+// references it, after which MessageID is set. The file itself lives in the
+// blob store under StorageKey.
+type Attachment struct {
+	ID          string    `gorm:"type:uuid;primaryKey" json:"id"`
+	TenantID    string    `gorm:"index;not null" json:"tenant_id"`
+	ChannelID   string    `gorm:"index;not null" json:"channel_id"` // Stream channel ID
+	MessageID   *string   `gorm:"index" json:"message_id,omitempty"`
+	UploaderID  string    `gorm:"not null" json:"uploader_id"`
+	Filename    string    `gorm:"not null" json:"filename"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	// URL is a signed download link, filled in when the attachment is returned; not stored
+	URL string `gorm:"-" json:"url,omitempty"`
+}
+
+func (a *Attachment) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AttachmentPolicy holds a tenant's upload limits. Tenants without a row use
+// the server defaults.
+type AttachmentPolicy struct {
+	TenantID string `gorm:"type:uuid;primaryKey" json:"-"`
+	MaxBytes int64  `gorm:"not null" json:"max_bytes"`
+	// AllowedTypes is a comma-separated list of MIME types; "image/*" matches any image
+	AllowedTypes string  `gorm:"not null" json:"allowed_types"`
+	Tenant       *Tenant `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Allows reports whether files of contentType may be uploaded under the policy
+func (p *AttachmentPolicy) Allows(contentType string) bool {
+	for _, allowed := range strings.Split(p.AllowedTypes, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == contentType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
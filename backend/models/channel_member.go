@@ -0,0 +1,34 @@
+// models/channel_member.go - Channel membership
+package models
+
+import "time"
+
+// ChannelRole is a user's role within one channel
+type ChannelRole string
+
+const (
+	ChannelRoleOwner     ChannelRole = "owner"
+	ChannelRoleModerator ChannelRole = "moderator"
+	ChannelRoleMember    ChannelRole = "member"
+)
+
+// ChannelMember records that a user belongs to a channel.
+// It is the source of truth for membership and is mirrored to the chat provider.
+type ChannelMember struct {
+	ChannelID string      `gorm:"type:uuid;primaryKey" json:"channel_id"`
+	UserID    string      `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	Role      ChannelRole `gorm:"not null;default:member" json:"role"`
+	JoinedAt  time.Time   `gorm:"autoCreateTime" json:"joined_at"`
+	Channel   *Channel    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	User      *User       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// CanManage reports whether the channel role may add and remove members
+func (r ChannelRole) CanManage() bool {
+	return r == ChannelRoleOwner || r == ChannelRoleModerator
+}
+
+// IsValid reports whether r is a known channel role
+func (r ChannelRole) IsValid() bool {
+	return r == ChannelRoleOwner || r == ChannelRoleModerator || r == ChannelRoleMember
+}
@@ -0,0 +1,112 @@
+// models/chat.go - Chat models shared by the chat providers
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Message is a chat message as returned by every chat provider.
+// The local provider also persists it in the messages table.
+type Message struct {
+	ID        string `gorm:"type:uuid;primaryKey" json:"id"`
+	ChannelID string `gorm:"index;not null" json:"channel_id"`
+	UserID    string `gorm:"not null" json:"user_id"`
+	Text      string `json:"text"`
+	// ParentID is the thread's top-level message for replies, empty otherwise
+	ParentID string `gorm:"index" json:"parent_id,omitempty"`
+	// ReplyCount is the number of replies in the thread started by this message
+	ReplyCount int       `gorm:"not null;default:0" json:"reply_count"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// EditedAt is set when the text was changed after sending
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// DeletedAt marks a tombstone: the message stays in the history with its text removed
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Reactions are aggregated per emoji for the requesting user; not stored
+	Reactions []ReactionSummary `gorm:"-" json:"reactions"`
+	// Attachments are the files sent with the message; not stored on the message row
+	Attachments []Attachment `gorm:"-" json:"attachments"`
+}
+
+func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ChatChannel is the local provider's record of a chat channel
+// (the equivalent of a Stream channel; the tenant-facing row lives in Channel)
+type ChatChannel struct {
+	ID          string `gorm:"primaryKey"`
+	TenantID    string `gorm:"index"`
+	Name        string
+	Description string
+	CreatedBy   string
+	// Frozen channels reject new messages (Stream's "frozen" flag)
+	Frozen    bool `gorm:"not null;default:false"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// ChatMember is the local provider's record of a channel member
+type ChatMember struct {
+	ChannelID string `gorm:"primaryKey"`
+	UserID    string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// MessageRevision keeps the text a message had before an edit or deletion,
+// so moderators can review the history independently of the chat provider
+type MessageRevision struct {
+	ID        string         `gorm:"type:uuid;primaryKey" json:"id"`
+	MessageID string         `gorm:"index;not null" json:"message_id"`
+	ChannelID string         `gorm:"not null" json:"channel_id"`
+	EditorID  string         `gorm:"not null" json:"editor_id"`
+	Action    RevisionAction `gorm:"not null" json:"action"`
+	Text      string         `json:"text"` // text before the change
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// RevisionAction is the change recorded by a MessageRevision
+type RevisionAction string
+
+const (
+	RevisionEdit   RevisionAction = "edit"
+	RevisionDelete RevisionAction = "delete"
+)
+
+func (r *MessageRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ThreadParticipant records that a user took part in a thread (its parent's
+// author and everyone who replied), so they can be notified of new replies
+type ThreadParticipant struct {
+	ParentID  string    `gorm:"primaryKey" json:"parent_id"`
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reaction is one user's emoji reaction to a message. The table is the
+// source of truth for aggregates and is mirrored to the chat provider.
+type Reaction struct {
+	MessageID string    `gorm:"primaryKey" json:"message_id"`
+	UserID    string    `gorm:"primaryKey" json:"user_id"`
+	Emoji     string    `gorm:"primaryKey" json:"emoji"`
+	ChannelID string    `gorm:"index;not null" json:"channel_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionSummary aggregates the reactions with one emoji on a message
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"` // the requesting user reacted with this emoji
+}
@@ -0,0 +1,36 @@
+// models/invite.go - Tenant invitations
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invite lets the holder of its signed token join TenantID with Role.
+// An invite is pending until it is accepted, revoked or expires.
+type Invite struct {
+	ID         string     `gorm:"type:uuid;primaryKey" json:"id"`
+	TenantID   string     `gorm:"type:uuid;index;not null" json:"tenant_id"`
+	Tenant     *Tenant    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Email      string     `gorm:"index;not null" json:"email"`
+	Role       Role       `gorm:"not null" json:"role"`
+	InvitedBy  string     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (i *Invite) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsPending reports whether the invite can still be accepted
+func (i *Invite) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,41 @@
+// models/mention.go - Users mentioned in messages
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MentionKind tells how a user was mentioned
+type MentionKind string
+
+const (
+	// MentionUser is an explicit @user mention
+	MentionUser MentionKind = "user"
+	// MentionChannel is @channel, which notifies every channel member
+	MentionChannel MentionKind = "channel"
+	// MentionHere is @here; without presence tracking it notifies every channel member
+	MentionHere MentionKind = "here"
+)
+
+// Mention records that UserID was mentioned in a message. A user gets at
+// most one mention per message.
+type Mention struct {
+	ID        string      `gorm:"type:uuid;primaryKey" json:"id"`
+	TenantID  string      `gorm:"index;not null" json:"tenant_id"`
+	UserID    string      `gorm:"not null;uniqueIndex:idx_mention_message_user,priority:2;index" json:"user_id"`
+	MessageID string      `gorm:"not null;uniqueIndex:idx_mention_message_user,priority:1" json:"message_id"`
+	ChannelID string      `gorm:"not null" json:"channel_id"` // Stream channel ID
+	AuthorID  string      `gorm:"not null" json:"author_id"`
+	Kind      MentionKind `gorm:"not null" json:"kind"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (m *Mention) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
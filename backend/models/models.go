@@ -2,6 +2,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -18,6 +20,8 @@ const (
 type Tenant struct {
 	ID   string `gorm:"type:uuid;primaryKey"`
 	Name string `gorm:"uniqueIndex;not null"`
+	// DeletedAt is set when the tenant is deleted; its users can no longer sign in
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
 func (t *Tenant) BeforeCreate(tx *gorm.DB) (err error) {
@@ -27,15 +31,25 @@ func (t *Tenant) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
-// User represents a user in the system
-// TenantID is set by backend logic, not by registration request
+// User represents a user account; it belongs to tenants through TenantMember.
+// The email identifies the account across tenants.
 type User struct {
 	ID       string `gorm:"type:uuid;primaryKey"`
 	Email    string `gorm:"uniqueIndex;not null"`
 	Name     string
-	Password string `gorm:"not null"`
-	Role     Role   `gorm:"default:MEMBER"`
-	TenantID string
+	Password string `gorm:"not null" json:"-"`
+	// Role and TenantID describe the user's membership in one tenant. They
+	// are read from tenant_members by tenant-scoped queries (db.TenantUsers)
+	// and are not columns of users; on create, AfterCreate adds the membership.
+	Role     Role   `gorm:"->;-:migration"`
+	TenantID string `gorm:"->;-:migration"`
+	// RemovedAt is set on users listed as removed from a tenant
+	RemovedAt *time.Time `gorm:"->;-:migration" json:",omitempty"`
+	// TokenVersion is embedded in access tokens; bumping it revokes them all
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+	// DeletedAt is set when the user is deactivated; the row is kept so
+	// message history still resolves its author
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -45,18 +59,98 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// AfterCreate makes the new user a member of TenantID with Role
+func (u *User) AfterCreate(tx *gorm.DB) (err error) {
+	if u.TenantID == "" {
+		return nil
+	}
+	if u.Role == "" {
+		u.Role = RoleMember
+	}
+	return tx.Create(&TenantMember{TenantID: u.TenantID, UserID: u.ID, Role: u.Role}).Error
+}
+
+// ChannelType controls who can see, join and post in a channel
+type ChannelType string
+
+const (
+	// ChannelPublic channels are listed to the whole tenant and anyone can join
+	ChannelPublic ChannelType = "public"
+	// ChannelPrivate channels are invite only and hidden from non-members
+	ChannelPrivate ChannelType = "private"
+	// ChannelAnnouncement channels are public, but only admins/moderators post
+	ChannelAnnouncement ChannelType = "announcement"
+	// ChannelDirect channels are DMs and group DMs with a fixed set of participants
+	ChannelDirect ChannelType = "direct"
+)
+
+// IsValid reports whether t is a named channel type; direct conversations
+// are not created as named channels
+func (t ChannelType) IsValid() bool {
+	return t == ChannelPublic || t == ChannelPrivate || t == ChannelAnnouncement
+}
+
+// MembersOnly reports whether only members may see and read channels of type t
+func (t ChannelType) MembersOnly() bool {
+	return t == ChannelPrivate || t == ChannelDirect
+}
+
 type Channel struct {
 	ID          string `gorm:"type:uuid;primaryKey"`
 	StreamID    string `gorm:"uniqueIndex;not null"`
 	Name        string
 	Description string
-	TenantID    string
-	CreatedBy   string
+	TenantID    string      `gorm:"type:uuid;not null;index"`
+	CreatedBy   string      `gorm:"type:uuid"`
+	Type        ChannelType `gorm:"not null;default:public"`
+	// DMKey identifies a direct conversation by its participants, so the
+	// same set of users always reuses one conversation (nil for named channels)
+	DMKey *string `gorm:"uniqueIndex"`
+	// AllowGuestPosting lets GUEST users post; otherwise guests are read-only
+	AllowGuestPosting bool `gorm:"not null;default:false"`
+	CreatedAt         time.Time
+	// ArchivedAt is set when the channel is archived; archived channels are read-only
+	ArchivedAt *time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	// Tenants and creators cannot be deleted while they have channels
+	Tenant  *Tenant `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	Creator *User   `gorm:"foreignKey:CreatedBy;constraint:OnDelete:RESTRICT" json:"-"`
 }
 
 func (c *Channel) BeforeCreate(tx *gorm.DB) (err error) {
 	if c.ID == "" {
 		c.ID = uuid.New().String()
 	}
+	if c.Type == "" {
+		c.Type = ChannelPublic
+	}
 	return nil
 }
+
+// RefreshToken is a server-side refresh token; only its SHA-256 hash is stored.
+// Tokens rotate on every refresh: the old row is revoked and points to its replacement.
+type RefreshToken struct {
+	ID     string `gorm:"type:uuid;primaryKey"`
+	UserID string `gorm:"type:uuid;index;not null"`
+	User   *User  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	// TenantID is the tenant the token signs in to
+	TenantID   string `gorm:"type:uuid"`
+	TokenHash  string `gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string
+	CreatedAt  time.Time
+}
+
+func (r *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// RevokedToken is a denylisted access token, kept until the token would have expired
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,108 @@
+// models/permission.go - Named permissions and the default role matrix
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission is a named action that can be granted to a role
+type Permission string
+
+const (
+	PermTenantCreate     Permission = "tenant.create"
+	PermTenantManage     Permission = "tenant.manage"
+	PermUserList         Permission = "user.list"
+	PermUserCreate       Permission = "user.create"
+	PermUserUpdate       Permission = "user.update"
+	PermUserDelete       Permission = "user.delete"
+	PermUserInvite       Permission = "user.invite"
+	PermChannelCreate    Permission = "channel.create"
+	PermChannelManage    Permission = "channel.manage"
+	PermMessageRead      Permission = "message.read"
+	PermMessageSend      Permission = "message.send"
+	PermMessageEditAny   Permission = "message.edit_any"
+	PermMessageDeleteAny Permission = "message.delete_any"
+	PermPermissionManage Permission = "permission.manage"
+)
+
+// AllPermissions lists every permission known to the server
+var AllPermissions = []Permission{
+	PermTenantCreate,
+	PermTenantManage,
+	PermUserList,
+	PermUserCreate,
+	PermUserUpdate,
+	PermUserDelete,
+	PermUserInvite,
+	PermChannelCreate,
+	PermChannelManage,
+	PermMessageRead,
+	PermMessageSend,
+	PermMessageEditAny,
+	PermMessageDeleteAny,
+	PermPermissionManage,
+}
+
+// BuiltinRoles lists the roles every tenant has
+var BuiltinRoles = []Role{RoleAdmin, RoleModerator, RoleMember, RoleGuest}
+
+// DefaultPermissions is the permission matrix used when a tenant has no overrides
+var DefaultPermissions = map[Role][]Permission{
+	RoleAdmin: AllPermissions,
+	RoleModerator: {
+		PermUserList, PermUserCreate, PermUserUpdate,
+		PermChannelCreate, PermChannelManage,
+		PermMessageRead, PermMessageSend, PermMessageEditAny, PermMessageDeleteAny,
+	},
+	RoleMember: {PermUserList, PermMessageRead, PermMessageSend},
+	RoleGuest:  {PermUserList, PermMessageRead},
+}
+
+// IsBuiltinRole reports whether role is one of BuiltinRoles
+func IsBuiltinRole(role Role) bool {
+	for _, r := range BuiltinRoles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionOverride grants (Allowed) or revokes a permission for a role
+// within one tenant, overriding DefaultPermissions
+type PermissionOverride struct {
+	TenantID   string     `gorm:"type:uuid;primaryKey" json:"tenant_id"`
+	Role       Role       `gorm:"primaryKey" json:"role"`
+	Permission Permission `gorm:"primaryKey" json:"permission"`
+	Allowed    bool       `gorm:"not null" json:"allowed"`
+	Tenant     *Tenant    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TenantRole is a custom role defined by a tenant (e.g. "Support Agent").
+// Users reference it by name in User.Role; its permissions are stored as
+// PermissionOverride rows for that name, on top of an empty default set.
+type TenantRole struct {
+	ID          string  `gorm:"type:uuid;primaryKey" json:"id"`
+	TenantID    string  `gorm:"type:uuid;uniqueIndex:idx_tenant_role_name;not null" json:"tenant_id"`
+	Name        string  `gorm:"uniqueIndex:idx_tenant_role_name;not null" json:"name"`
+	Description string  `json:"description"`
+	Tenant      *Tenant `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (r *TenantRole) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// models/tenant_member.go - Tenant membership
+package models
+
+import "time"
+
+// TenantMember records that a user belongs to a tenant with a per-tenant role.
+// A user can belong to several tenants; access tokens are scoped to one of them.
+type TenantMember struct {
+	TenantID string    `gorm:"type:uuid;primaryKey" json:"tenant_id"`
+	UserID   string    `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	Role     Role      `gorm:"not null;default:MEMBER" json:"role"`
+	JoinedAt time.Time `gorm:"autoCreateTime" json:"joined_at"`
+	// RemovedAt is set when the user is removed from the tenant; the
+	// membership can be restored during the grace period
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+	// Tenants cannot be deleted while they have members
+	Tenant *Tenant `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	User   *User   `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,328 @@
+// repository/gorm.go - Repositories backed by a Gorm database
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+// NewGorm returns repositories that read and write conn
+func NewGorm(conn *gorm.DB) Repos {
+	return Repos{
+		Tenants:     &gormTenants{conn},
+		Users:       &gormUsers{conn},
+		Channels:    &gormChannels{conn},
+		Roles:       &gormRoles{conn},
+		Invites:     &gormInvites{conn},
+		Attachments: &gormAttachments{conn},
+		Mentions:    &gormMentions{conn},
+		tx: func(ctx context.Context, fn func(Repos) error) error {
+			return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(NewGorm(tx))
+			})
+		},
+	}
+}
+
+// translate maps Gorm errors to the package's errors
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+type gormTenants struct {
+	db *gorm.DB
+}
+
+func (r *gormTenants) Create(ctx context.Context, tenant *models.Tenant) error {
+	return translate(r.db.WithContext(ctx).Create(tenant).Error)
+}
+
+func (r *gormTenants) Get(ctx context.Context, id string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.db.WithContext(ctx).First(&tenant, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &tenant, nil
+}
+
+func (r *gormTenants) GetDeleted(ctx context.Context, id string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&tenant, "id = ?", id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &tenant, nil
+}
+
+func (r *gormTenants) NameTaken(ctx context.Context, name string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.Tenant{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormTenants) List(ctx context.Context, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error) {
+	return db.Paginate[models.Tenant](r.db.WithContext(ctx), spec, opts)
+}
+
+func (r *gormTenants) ListDeleted(ctx context.Context, userID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error) {
+	memberOf := r.db.Model(&models.TenantMember{}).Select("tenant_id").Where("user_id = ? AND removed_at IS NULL", userID)
+	query := r.db.WithContext(ctx).Unscoped().Where("deleted_at > ? AND id IN (?)", since, memberOf)
+	return db.Paginate[models.Tenant](query, spec, opts)
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+// members returns a session over the members of tenantID (see db.TenantUsers)
+func (r *gormUsers) members(ctx context.Context, tenantID string, removed bool) *gorm.DB {
+	return db.Members(r.db.WithContext(ctx), tenantID, removed)
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	// User.AfterCreate adds the membership
+	return translate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUsers) Get(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Unscoped().Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) EmailTaken(ctx context.Context, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("LOWER(email) = ?", strings.ToLower(email)).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUsers) Tenants(ctx context.Context, userID string) ([]UserTenant, error) {
+	tenants := []UserTenant{}
+	err := r.db.WithContext(ctx).Model(&models.TenantMember{}).
+		Select("tenants.id", "tenants.name", "tenant_members.role").
+		Joins("JOIN tenants ON tenants.id = tenant_members.tenant_id AND tenants.deleted_at IS NULL").
+		Where("tenant_members.user_id = ? AND tenant_members.removed_at IS NULL", userID).
+		Order("tenant_members.joined_at, tenants.name").
+		Scan(&tenants).Error
+	return tenants, err
+}
+
+func (r *gormUsers) Member(ctx context.Context, tenantID, id string) (*models.User, error) {
+	var user models.User
+	if err := r.members(ctx, tenantID, false).First(&user, "users.id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) RemovedMember(ctx context.Context, tenantID, id string) (*models.User, error) {
+	var user models.User
+	if err := r.members(ctx, tenantID, true).First(&user, "users.id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) CountMembers(ctx context.Context, tenantID string, ids []string) (int, error) {
+	var count int64
+	err := r.members(ctx, tenantID, false).Where("users.id IN ?", ids).Count(&count).Error
+	return int(count), err
+}
+
+func (r *gormUsers) List(ctx context.Context, tenantID string, filter UserFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error) {
+	query := r.members(ctx, tenantID, false)
+	if filter.Role != "" {
+		query = query.Where("tenant_members.role = ?", filter.Role)
+	}
+	return db.Paginate[models.User](query, spec, opts)
+}
+
+func (r *gormUsers) ListRemoved(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error) {
+	query := r.members(ctx, tenantID, true).Where("tenant_members.removed_at > ?", since)
+	return db.Paginate[models.User](query, spec, opts)
+}
+
+func (r *gormUsers) UpdateRole(ctx context.Context, tenantID, userID string, role models.Role) error {
+	return r.db.WithContext(ctx).Model(&models.TenantMember{}).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).Update("role", role).Error
+}
+
+func (r *gormUsers) UpdateAccount(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"name": user.Name, "email": user.Email}).Error)
+}
+
+type gormChannels struct {
+	db *gorm.DB
+}
+
+func (r *gormChannels) tenant(ctx context.Context, tenantID string) *gorm.DB {
+	return r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID))
+}
+
+func (r *gormChannels) Create(ctx context.Context, channel *models.Channel) error {
+	return translate(r.db.WithContext(ctx).Create(channel).Error)
+}
+
+func (r *gormChannels) Get(ctx context.Context, tenantID, id string) (*models.Channel, error) {
+	var channel models.Channel
+	if err := r.tenant(ctx, tenantID).First(&channel, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &channel, nil
+}
+
+func (r *gormChannels) GetByStreamID(ctx context.Context, tenantID, streamID string) (*models.Channel, error) {
+	var channel models.Channel
+	if err := r.tenant(ctx, tenantID).First(&channel, "stream_id = ?", streamID).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &channel, nil
+}
+
+func (r *gormChannels) GetDeleted(ctx context.Context, tenantID, id string) (*models.Channel, error) {
+	var channel models.Channel
+	err := r.tenant(ctx, tenantID).Unscoped().Where("deleted_at IS NOT NULL AND type <> ?", models.ChannelDirect).
+		First(&channel, "id = ?", id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &channel, nil
+}
+
+// memberOf selects the IDs of the channels userID is a member of
+func (r *gormChannels) memberOf(userID string) *gorm.DB {
+	return r.db.Model(&models.ChannelMember{}).Select("channel_id").Where("user_id = ?", userID)
+}
+
+func (r *gormChannels) List(ctx context.Context, tenantID string, filter ChannelFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error) {
+	query := r.tenant(ctx, tenantID).Where("type <> ?", models.ChannelDirect).
+		Where("type <> ? OR id IN (?)", models.ChannelPrivate, r.memberOf(filter.VisibleTo))
+	if !filter.IncludeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	return db.Paginate[models.Channel](query, spec, opts)
+}
+
+func (r *gormChannels) ListDeleted(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error) {
+	query := r.tenant(ctx, tenantID).Unscoped().
+		Where("deleted_at > ? AND type <> ?", since, models.ChannelDirect)
+	return db.Paginate[models.Channel](query, spec, opts)
+}
+
+func (r *gormChannels) VisibleStreamIDs(ctx context.Context, tenantID, userID string) ([]string, error) {
+	var ids []string
+	err := r.tenant(ctx, tenantID).Model(&models.Channel{}).
+		Where("type NOT IN ? OR id IN (?)", []models.ChannelType{models.ChannelPrivate, models.ChannelDirect}, r.memberOf(userID)).
+		Pluck("stream_id", &ids).Error
+	return ids, err
+}
+
+type gormRoles struct {
+	db *gorm.DB
+}
+
+func (r *gormRoles) Get(ctx context.Context, tenantID, id string) (*models.TenantRole, error) {
+	var role models.TenantRole
+	if err := r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID)).First(&role, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &role, nil
+}
+
+func (r *gormRoles) List(ctx context.Context, tenantID string) ([]models.TenantRole, error) {
+	roles := []models.TenantRole{}
+	err := r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID)).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *gormRoles) UpdateDescription(ctx context.Context, role *models.TenantRole) error {
+	return r.db.WithContext(ctx).Model(role).Update("description", role.Description).Error
+}
+
+type gormInvites struct {
+	db *gorm.DB
+}
+
+func (r *gormInvites) ListPending(ctx context.Context, tenantID string) ([]models.Invite, error) {
+	invites := []models.Invite{}
+	err := r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID)).
+		Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").Find(&invites).Error
+	return invites, err
+}
+
+func (r *gormInvites) Revoke(ctx context.Context, tenantID, id string) error {
+	res := r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID)).Model(&models.Invite{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+type gormAttachments struct {
+	db *gorm.DB
+}
+
+func (r *gormAttachments) Get(ctx context.Context, id string) (*models.Attachment, error) {
+	var att models.Attachment
+	if err := r.db.WithContext(ctx).First(&att, "id = ?", id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &att, nil
+}
+
+func (r *gormAttachments) Policy(ctx context.Context, tenantID string) (*models.AttachmentPolicy, error) {
+	var policy models.AttachmentPolicy
+	if err := r.db.WithContext(ctx).First(&policy, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &policy, nil
+}
+
+func (r *gormAttachments) SavePolicy(ctx context.Context, policy *models.AttachmentPolicy) error {
+	return r.db.WithContext(ctx).Save(policy).Error
+}
+
+type gormMentions struct {
+	db *gorm.DB
+}
+
+func (r *gormMentions) List(ctx context.Context, tenantID, userID string, filter MentionFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Mention], error) {
+	query := r.db.WithContext(ctx).Scopes(db.TenantScope(tenantID)).Where("user_id = ?", userID)
+	if filter.ChannelID != "" {
+		query = query.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	return db.Paginate[models.Mention](query, spec, opts)
+}
@@ -0,0 +1,616 @@
+// repository/memory.go - In-memory repositories for tests
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/google/uuid"
+)
+
+// Memory keeps tenants, users, channels and their related rows in maps. It
+// implements the same lookups, filters and pagination as the Gorm
+// repositories without a database.
+type Memory struct {
+	mu          sync.RWMutex
+	tenants     map[string]models.Tenant
+	users       map[string]models.User                    // accounts, without TenantID and Role
+	members     map[string]map[string]models.TenantMember // by tenant ID, then user ID
+	channels    map[string]models.Channel
+	joined      map[string]map[string]bool // channel members, by channel ID
+	roles       map[string]models.TenantRole
+	invites     map[string]models.Invite
+	attachments map[string]models.Attachment
+	policies    map[string]models.AttachmentPolicy // by tenant ID
+	mentions    map[string]models.Mention
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		tenants:  make(map[string]models.Tenant),
+		users:    make(map[string]models.User),
+		members:  make(map[string]map[string]models.TenantMember),
+		channels: make(map[string]models.Channel),
+		joined:   make(map[string]map[string]bool),
+
+		roles:       make(map[string]models.TenantRole),
+		invites:     make(map[string]models.Invite),
+		attachments: make(map[string]models.Attachment),
+		policies:    make(map[string]models.AttachmentPolicy),
+		mentions:    make(map[string]models.Mention),
+	}
+}
+
+// Repos returns repositories backed by m
+func (m *Memory) Repos() Repos {
+	return Repos{
+		Tenants:     &memoryTenants{m},
+		Users:       &memoryUsers{m},
+		Channels:    &memoryChannels{m},
+		Roles:       &memoryRoles{m},
+		Invites:     &memoryInvites{m},
+		Attachments: &memoryAttachments{m},
+		Mentions:    &memoryMentions{m},
+		tx: func(ctx context.Context, fn func(Repos) error) error {
+			restore := m.snapshot()
+			err := fn(m.Repos())
+			if err != nil {
+				restore()
+			}
+			return err
+		},
+	}
+}
+
+// snapshot copies the store and returns a function that puts the copy back.
+// It gives Transaction its rollback, though not isolation from other writers.
+func (m *Memory) snapshot() func() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tenants, users, channels := copyMap(m.tenants), copyMap(m.users), copyMap(m.channels)
+	members := make(map[string]map[string]models.TenantMember, len(m.members))
+	for id, byUser := range m.members {
+		members[id] = copyMap(byUser)
+	}
+	joined := make(map[string]map[string]bool, len(m.joined))
+	for id, byUser := range m.joined {
+		joined[id] = copyMap(byUser)
+	}
+	roles, invites, attachments := copyMap(m.roles), copyMap(m.invites), copyMap(m.attachments)
+	policies, mentions := copyMap(m.policies), copyMap(m.mentions)
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.tenants, m.users, m.members, m.channels, m.joined = tenants, users, members, channels, joined
+		m.roles, m.invites, m.attachments, m.policies, m.mentions = roles, invites, attachments, policies, mentions
+	}
+}
+
+// assignID gives a new row an ID, as the models' BeforeCreate hooks do for Gorm
+func assignID(id *string) {
+	if *id == "" {
+		*id = uuid.NewString()
+	}
+}
+
+func copyMap[K comparable, V any](in map[K]V) map[K]V {
+	out := make(map[K]V, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// AddChannelMember records that userID is a member of channelID, which makes
+// private channels visible to them. Channel membership itself is managed by
+// services.AddChannelMembers.
+func (m *Memory) AddChannelMember(channelID, userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.joined[channelID] == nil {
+		m.joined[channelID] = make(map[string]bool)
+	}
+	m.joined[channelID][userID] = true
+}
+
+// AddRole stores a custom role, which services.CreateRole creates in the database
+func (m *Memory) AddRole(role models.TenantRole) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assignID(&role.ID)
+	m.roles[role.ID] = role
+}
+
+// AddInvite stores an invite, which services.CreateInvite creates in the database
+func (m *Memory) AddInvite(invite models.Invite) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assignID(&invite.ID)
+	m.invites[invite.ID] = invite
+}
+
+// AddAttachment stores an attachment, which services.SaveAttachment uploads
+// and creates in the database
+func (m *Memory) AddAttachment(att models.Attachment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assignID(&att.ID)
+	m.attachments[att.ID] = att
+}
+
+// AddMention stores a mention, which services.RecordMentions creates in the database
+func (m *Memory) AddMention(mention models.Mention) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assignID(&mention.ID)
+	m.mentions[mention.ID] = mention
+}
+
+type memoryTenants struct {
+	m *Memory
+}
+
+func (r *memoryTenants) Create(ctx context.Context, tenant *models.Tenant) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, t := range r.m.tenants {
+		if t.Name == tenant.Name {
+			return ErrDuplicate
+		}
+	}
+	assignID(&tenant.ID)
+	r.m.tenants[tenant.ID] = *tenant
+	return nil
+}
+
+func (r *memoryTenants) Get(ctx context.Context, id string) (*models.Tenant, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	tenant, ok := r.m.tenants[id]
+	if !ok || tenant.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &tenant, nil
+}
+
+func (r *memoryTenants) GetDeleted(ctx context.Context, id string) (*models.Tenant, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	tenant, ok := r.m.tenants[id]
+	if !ok || !tenant.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &tenant, nil
+}
+
+func (r *memoryTenants) NameTaken(ctx context.Context, name string) (bool, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	for _, t := range r.m.tenants {
+		if t.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryTenants) List(ctx context.Context, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error) {
+	return r.list(spec, opts, func(t models.Tenant) bool { return !t.DeletedAt.Valid })
+}
+
+func (r *memoryTenants) ListDeleted(ctx context.Context, userID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error) {
+	return r.list(spec, opts, func(t models.Tenant) bool {
+		member, ok := r.m.members[t.ID][userID]
+		return t.DeletedAt.Valid && t.DeletedAt.Time.After(since) && ok && member.RemovedAt == nil
+	})
+}
+
+func (r *memoryTenants) list(spec db.ListSpec, opts db.ListOptions, keep func(models.Tenant) bool) (*db.Page[models.Tenant], error) {
+	r.m.mu.RLock()
+	var tenants []models.Tenant
+	for _, t := range r.m.tenants {
+		if keep(t) {
+			tenants = append(tenants, t)
+		}
+	}
+	r.m.mu.RUnlock()
+	return db.PaginateSlice(tenants, spec, opts)
+}
+
+type memoryUsers struct {
+	m *Memory
+}
+
+func (r *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, u := range r.m.users {
+		if u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	assignID(&user.ID)
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+	account := *user
+	account.TenantID, account.Role = "", ""
+	r.m.users[user.ID] = account
+	if user.TenantID != "" {
+		if r.m.members[user.TenantID] == nil {
+			r.m.members[user.TenantID] = make(map[string]models.TenantMember)
+		}
+		r.m.members[user.TenantID][user.ID] = models.TenantMember{
+			TenantID: user.TenantID, UserID: user.ID, Role: user.Role, JoinedAt: time.Now(),
+		}
+	}
+	return nil
+}
+
+func (r *memoryUsers) Get(ctx context.Context, id string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	user, ok := r.m.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	for _, u := range r.m.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) EmailTaken(ctx context.Context, email string) (bool, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	for _, u := range r.m.users {
+		if strings.EqualFold(u.Email, email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUsers) Tenants(ctx context.Context, userID string) ([]UserTenant, error) {
+	r.m.mu.RLock()
+	var joined []models.TenantMember
+	for tenantID, members := range r.m.members {
+		member, ok := members[userID]
+		if ok && member.RemovedAt == nil && !r.m.tenants[tenantID].DeletedAt.Valid {
+			joined = append(joined, member)
+		}
+	}
+	sort.Slice(joined, func(i, j int) bool {
+		if !joined[i].JoinedAt.Equal(joined[j].JoinedAt) {
+			return joined[i].JoinedAt.Before(joined[j].JoinedAt)
+		}
+		return r.m.tenants[joined[i].TenantID].Name < r.m.tenants[joined[j].TenantID].Name
+	})
+	tenants := []UserTenant{}
+	for _, member := range joined {
+		tenants = append(tenants, UserTenant{ID: member.TenantID, Name: r.m.tenants[member.TenantID].Name, Role: member.Role})
+	}
+	r.m.mu.RUnlock()
+	return tenants, nil
+}
+
+// member returns userID as a member of tenantID, if they are one. Removed
+// members are only returned when removed is true, and active ones when it is
+// false; deleted accounts can only be removed members.
+func (r *memoryUsers) member(tenantID, userID string, removed bool) (models.User, bool) {
+	member, ok := r.m.members[tenantID][userID]
+	user, found := r.m.users[userID]
+	if !ok || !found || (member.RemovedAt != nil) != removed || (user.DeletedAt.Valid && !removed) {
+		return models.User{}, false
+	}
+	user.TenantID, user.Role, user.RemovedAt = member.TenantID, member.Role, member.RemovedAt
+	return user, true
+}
+
+func (r *memoryUsers) Member(ctx context.Context, tenantID, id string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	user, ok := r.member(tenantID, id, false)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) RemovedMember(ctx context.Context, tenantID, id string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	user, ok := r.member(tenantID, id, true)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) CountMembers(ctx context.Context, tenantID string, ids []string) (int, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	count := 0
+	for _, id := range ids {
+		if _, ok := r.member(tenantID, id, false); ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryUsers) List(ctx context.Context, tenantID string, filter UserFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error) {
+	return r.list(tenantID, false, spec, opts, func(u models.User) bool {
+		return filter.Role == "" || u.Role == filter.Role
+	})
+}
+
+func (r *memoryUsers) ListRemoved(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error) {
+	return r.list(tenantID, true, spec, opts, func(u models.User) bool {
+		return u.RemovedAt.After(since)
+	})
+}
+
+func (r *memoryUsers) list(tenantID string, removed bool, spec db.ListSpec, opts db.ListOptions, keep func(models.User) bool) (*db.Page[models.User], error) {
+	r.m.mu.RLock()
+	var users []models.User
+	for id := range r.m.members[tenantID] {
+		if user, ok := r.member(tenantID, id, removed); ok && keep(user) {
+			users = append(users, user)
+		}
+	}
+	r.m.mu.RUnlock()
+	return db.PaginateSlice(users, spec, opts)
+}
+
+func (r *memoryUsers) UpdateRole(ctx context.Context, tenantID, userID string, role models.Role) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if member, ok := r.m.members[tenantID][userID]; ok {
+		member.Role = role
+		r.m.members[tenantID][userID] = member
+	}
+	return nil
+}
+
+func (r *memoryUsers) UpdateAccount(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	account, ok := r.m.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for id, u := range r.m.users {
+		if id != user.ID && u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	account.Name, account.Email = user.Name, user.Email
+	r.m.users[user.ID] = account
+	return nil
+}
+
+type memoryChannels struct {
+	m *Memory
+}
+
+func (r *memoryChannels) Create(ctx context.Context, channel *models.Channel) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, ch := range r.m.channels {
+		if ch.StreamID == channel.StreamID || (ch.DMKey != nil && channel.DMKey != nil && *ch.DMKey == *channel.DMKey) {
+			return ErrDuplicate
+		}
+	}
+	assignID(&channel.ID)
+	if channel.Type == "" {
+		channel.Type = models.ChannelPublic
+	}
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = time.Now()
+	}
+	r.m.channels[channel.ID] = *channel
+	return nil
+}
+
+// find returns the first channel of tenantID that matches
+func (r *memoryChannels) find(tenantID string, match func(models.Channel) bool) (*models.Channel, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	for _, ch := range r.m.channels {
+		if ch.TenantID == tenantID && match(ch) {
+			return &ch, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryChannels) Get(ctx context.Context, tenantID, id string) (*models.Channel, error) {
+	return r.find(tenantID, func(ch models.Channel) bool { return ch.ID == id && !ch.DeletedAt.Valid })
+}
+
+func (r *memoryChannels) GetByStreamID(ctx context.Context, tenantID, streamID string) (*models.Channel, error) {
+	return r.find(tenantID, func(ch models.Channel) bool { return ch.StreamID == streamID && !ch.DeletedAt.Valid })
+}
+
+func (r *memoryChannels) GetDeleted(ctx context.Context, tenantID, id string) (*models.Channel, error) {
+	return r.find(tenantID, func(ch models.Channel) bool {
+		return ch.ID == id && ch.DeletedAt.Valid && ch.Type != models.ChannelDirect
+	})
+}
+
+// visible reports whether userID can read ch; the read lock must be held
+func (r *memoryChannels) visible(ch models.Channel, userID string) bool {
+	return !ch.Type.MembersOnly() || r.m.joined[ch.ID][userID]
+}
+
+func (r *memoryChannels) List(ctx context.Context, tenantID string, filter ChannelFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error) {
+	return r.list(tenantID, spec, opts, func(ch models.Channel) bool {
+		return !ch.DeletedAt.Valid && ch.Type != models.ChannelDirect && r.visible(ch, filter.VisibleTo) &&
+			(filter.IncludeArchived || ch.ArchivedAt == nil) &&
+			(filter.Type == "" || ch.Type == filter.Type)
+	})
+}
+
+func (r *memoryChannels) ListDeleted(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error) {
+	return r.list(tenantID, spec, opts, func(ch models.Channel) bool {
+		return ch.DeletedAt.Valid && ch.DeletedAt.Time.After(since) && ch.Type != models.ChannelDirect
+	})
+}
+
+func (r *memoryChannels) list(tenantID string, spec db.ListSpec, opts db.ListOptions, keep func(models.Channel) bool) (*db.Page[models.Channel], error) {
+	r.m.mu.RLock()
+	var channels []models.Channel
+	for _, ch := range r.m.channels {
+		if ch.TenantID == tenantID && keep(ch) {
+			channels = append(channels, ch)
+		}
+	}
+	r.m.mu.RUnlock()
+	return db.PaginateSlice(channels, spec, opts)
+}
+
+func (r *memoryChannels) VisibleStreamIDs(ctx context.Context, tenantID, userID string) ([]string, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	var ids []string
+	for _, ch := range r.m.channels {
+		if ch.TenantID == tenantID && !ch.DeletedAt.Valid && r.visible(ch, userID) {
+			ids = append(ids, ch.StreamID)
+		}
+	}
+	return ids, nil
+}
+
+type memoryRoles struct {
+	m *Memory
+}
+
+func (r *memoryRoles) Get(ctx context.Context, tenantID, id string) (*models.TenantRole, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	role, ok := r.m.roles[id]
+	if !ok || role.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return &role, nil
+}
+
+func (r *memoryRoles) List(ctx context.Context, tenantID string) ([]models.TenantRole, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	roles := []models.TenantRole{}
+	for _, role := range r.m.roles {
+		if role.TenantID == tenantID {
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *memoryRoles) UpdateDescription(ctx context.Context, role *models.TenantRole) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	stored, ok := r.m.roles[role.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Description = role.Description
+	r.m.roles[role.ID] = stored
+	return nil
+}
+
+type memoryInvites struct {
+	m *Memory
+}
+
+func (r *memoryInvites) ListPending(ctx context.Context, tenantID string) ([]models.Invite, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	now := time.Now()
+	invites := []models.Invite{}
+	for _, inv := range r.m.invites {
+		if inv.TenantID == tenantID && inv.AcceptedAt == nil && inv.RevokedAt == nil && inv.ExpiresAt.After(now) {
+			invites = append(invites, inv)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.After(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (r *memoryInvites) Revoke(ctx context.Context, tenantID, id string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	inv, ok := r.m.invites[id]
+	if !ok || inv.TenantID != tenantID || inv.AcceptedAt != nil || inv.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	inv.RevokedAt = &now
+	r.m.invites[id] = inv
+	return nil
+}
+
+type memoryAttachments struct {
+	m *Memory
+}
+
+func (r *memoryAttachments) Get(ctx context.Context, id string) (*models.Attachment, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	att, ok := r.m.attachments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &att, nil
+}
+
+func (r *memoryAttachments) Policy(ctx context.Context, tenantID string) (*models.AttachmentPolicy, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	policy, ok := r.m.policies[tenantID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &policy, nil
+}
+
+func (r *memoryAttachments) SavePolicy(ctx context.Context, policy *models.AttachmentPolicy) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.policies[policy.TenantID] = *policy
+	return nil
+}
+
+type memoryMentions struct {
+	m *Memory
+}
+
+func (r *memoryMentions) List(ctx context.Context, tenantID, userID string, filter MentionFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Mention], error) {
+	r.m.mu.RLock()
+	var mentions []models.Mention
+	for _, mention := range r.m.mentions {
+		if mention.TenantID == tenantID && mention.UserID == userID &&
+			(filter.ChannelID == "" || mention.ChannelID == filter.ChannelID) &&
+			(filter.Kind == "" || mention.Kind == filter.Kind) {
+			mentions = append(mentions, mention)
+		}
+	}
+	r.m.mu.RUnlock()
+	return db.PaginateSlice(mentions, spec, opts)
+}
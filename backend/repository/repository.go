@@ -0,0 +1,164 @@
+// repository/repository.go - Data access for tenants, users and channels
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+)
+
+var (
+	// ErrNotFound is returned when no row matches; rows of other tenants are not found
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a unique name or email is already in use
+	ErrDuplicate = errors.New("already exists")
+)
+
+// Repos are the repositories the HTTP handlers depend on. The services
+// package does not use them yet and still queries db.DB directly.
+type Repos struct {
+	Tenants     TenantRepo
+	Users       UserRepo
+	Channels    ChannelRepo
+	Roles       RoleRepo
+	Invites     InviteRepo
+	Attachments AttachmentRepo
+	Mentions    MentionRepo
+
+	tx func(ctx context.Context, fn func(Repos) error) error
+}
+
+// Transaction runs fn with repositories whose changes are all kept if fn
+// returns nil and all discarded otherwise
+func (r Repos) Transaction(ctx context.Context, fn func(Repos) error) error {
+	if r.tx == nil {
+		return fn(r)
+	}
+	return r.tx(ctx, fn)
+}
+
+// TenantRepo stores tenants (organizations)
+type TenantRepo interface {
+	Create(ctx context.Context, tenant *models.Tenant) error
+	// Get returns an active tenant
+	Get(ctx context.Context, id string) (*models.Tenant, error)
+	// GetDeleted returns a soft-deleted tenant
+	GetDeleted(ctx context.Context, id string) (*models.Tenant, error)
+	// NameTaken reports whether a tenant, deleted or not, is called name
+	NameTaken(ctx context.Context, name string) (bool, error)
+	List(ctx context.Context, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error)
+	// ListDeleted lists the tenants deleted after since that userID is an
+	// active member of
+	ListDeleted(ctx context.Context, userID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Tenant], error)
+}
+
+// UserTenant is a tenant a user can sign in to, with their role in it
+type UserTenant struct {
+	ID   string      `json:"id"`
+	Name string      `json:"name"`
+	Role models.Role `json:"role"`
+}
+
+// UserFilter narrows UserRepo.List; empty fields match every user
+type UserFilter struct {
+	Role models.Role
+}
+
+// UserRepo stores user accounts and their tenant memberships. Users read
+// through a tenant (Member, List, ...) have TenantID and Role set from their
+// membership there.
+type UserRepo interface {
+	// Create adds an account that is a member of user.TenantID with user.Role
+	Create(ctx context.Context, user *models.User) error
+	// Get returns an active account
+	Get(ctx context.Context, id string) (*models.User, error)
+	// FindByEmail returns the account with email, even if it was deleted
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// EmailTaken reports whether an account, deleted or not, uses email (ignoring case)
+	EmailTaken(ctx context.Context, email string) (bool, error)
+	// Tenants lists the active tenants userID is an active member of, in the
+	// order they were joined
+	Tenants(ctx context.Context, userID string) ([]UserTenant, error)
+	// Member returns an active member of tenantID
+	Member(ctx context.Context, tenantID, id string) (*models.User, error)
+	// RemovedMember returns a user removed from tenantID, with RemovedAt set
+	RemovedMember(ctx context.Context, tenantID, id string) (*models.User, error)
+	// CountMembers counts how many of ids are active members of tenantID
+	CountMembers(ctx context.Context, tenantID string, ids []string) (int, error)
+	List(ctx context.Context, tenantID string, filter UserFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error)
+	// ListRemoved lists the users removed from tenantID after since
+	ListRemoved(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.User], error)
+	// UpdateRole changes userID's role in tenantID
+	UpdateRole(ctx context.Context, tenantID, userID string, role models.Role) error
+	// UpdateAccount saves the account's name and email, which are shared by
+	// all of its tenants; it returns ErrDuplicate if the email is in use
+	UpdateAccount(ctx context.Context, user *models.User) error
+}
+
+// ChannelFilter narrows ChannelRepo.List
+type ChannelFilter struct {
+	// VisibleTo is the user whose private channels are listed; other private
+	// channels are left out
+	VisibleTo       string
+	Type            models.ChannelType // any type if empty
+	IncludeArchived bool
+}
+
+// ChannelRepo stores the channels of each tenant. Lookups take the caller's
+// tenant so that channels of other tenants are not found.
+type ChannelRepo interface {
+	Create(ctx context.Context, channel *models.Channel) error
+	Get(ctx context.Context, tenantID, id string) (*models.Channel, error)
+	GetByStreamID(ctx context.Context, tenantID, streamID string) (*models.Channel, error)
+	// GetDeleted returns a soft-deleted named channel (not a direct conversation)
+	GetDeleted(ctx context.Context, tenantID, id string) (*models.Channel, error)
+	// List lists named channels; direct conversations are never included
+	List(ctx context.Context, tenantID string, filter ChannelFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error)
+	// ListDeleted lists the named channels deleted after since
+	ListDeleted(ctx context.Context, tenantID string, since time.Time, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Channel], error)
+	// VisibleStreamIDs returns the Stream IDs of the channels and conversations
+	// userID can read: public and announcement channels, and the private
+	// channels and conversations they are a member of
+	VisibleStreamIDs(ctx context.Context, tenantID, userID string) ([]string, error)
+}
+
+// RoleRepo stores the custom roles of each tenant; built-in roles are not stored
+type RoleRepo interface {
+	Get(ctx context.Context, tenantID, id string) (*models.TenantRole, error)
+	// List returns the custom roles of tenantID ordered by name
+	List(ctx context.Context, tenantID string) ([]models.TenantRole, error)
+	UpdateDescription(ctx context.Context, role *models.TenantRole) error
+}
+
+// InviteRepo stores invites to join a tenant
+type InviteRepo interface {
+	// ListPending lists the invites of tenantID that can still be accepted, newest first
+	ListPending(ctx context.Context, tenantID string) ([]models.Invite, error)
+	// Revoke revokes a pending invite of tenantID
+	Revoke(ctx context.Context, tenantID, id string) error
+}
+
+// AttachmentRepo stores attachments and the upload policy of each tenant
+type AttachmentRepo interface {
+	// Get returns an attachment of any tenant; downloads are authorized by
+	// their signed URL instead
+	Get(ctx context.Context, id string) (*models.Attachment, error)
+	// Policy returns the upload policy tenantID configured, or ErrNotFound
+	Policy(ctx context.Context, tenantID string) (*models.AttachmentPolicy, error)
+	SavePolicy(ctx context.Context, policy *models.AttachmentPolicy) error
+}
+
+// MentionFilter narrows MentionRepo.List; empty fields match every mention
+type MentionFilter struct {
+	ChannelID string // Stream channel ID
+	Kind      models.MentionKind
+}
+
+// MentionRepo stores who was mentioned in which message
+type MentionRepo interface {
+	// List lists the mentions of userID in tenantID
+	List(ctx context.Context, tenantID, userID string, filter MentionFilter, spec db.ListSpec, opts db.ListOptions) (*db.Page[models.Mention], error)
+}
@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+// backend is one implementation under test, with ways to add channel
+// members, delete tenants and store rows that services create (which the
+// repositories do not do themselves)
+type backend struct {
+	repos        Repos
+	join         func(t *testing.T, channelID, userID string)
+	deleteTenant func(id string) error
+	seed         func(t *testing.T, row interface{})
+}
+
+// forEachBackend runs test against the Gorm and the in-memory repositories,
+// so both follow the same contract
+func forEachBackend(t *testing.T, test func(t *testing.T, b backend)) {
+	t.Run("gorm", func(t *testing.T) {
+		conn, err := db.OpenMemory()
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		test(t, backend{
+			repos: NewGorm(conn),
+			join: func(t *testing.T, channelID, userID string) {
+				t.Helper()
+				if err := conn.Create(&models.ChannelMember{ChannelID: channelID, UserID: userID}).Error; err != nil {
+					t.Fatalf("join: %v", err)
+				}
+			},
+			deleteTenant: func(id string) error { return conn.Delete(&models.Tenant{ID: id}).Error },
+			seed: func(t *testing.T, row interface{}) {
+				t.Helper()
+				if err := conn.Create(row).Error; err != nil {
+					t.Fatalf("seed %T: %v", row, err)
+				}
+			},
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		m := NewMemory()
+		test(t, backend{
+			repos: m.Repos(),
+			join:  func(t *testing.T, channelID, userID string) { m.AddChannelMember(channelID, userID) },
+			deleteTenant: func(id string) error {
+				tenant := m.tenants[id]
+				tenant.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+				m.tenants[id] = tenant
+				return nil
+			},
+			seed: func(t *testing.T, row interface{}) {
+				switch row := row.(type) {
+				case *models.TenantRole:
+					m.AddRole(*row)
+				case *models.Invite:
+					m.AddInvite(*row)
+				case *models.Attachment:
+					m.AddAttachment(*row)
+				case *models.Mention:
+					m.AddMention(*row)
+				default:
+					t.Fatalf("cannot seed %T", row)
+				}
+			},
+		})
+	})
+}
+
+var nameSpec = db.ListSpec{
+	Sorts:       map[string]db.SortField{"name": {Column: "name"}},
+	DefaultSort: "name",
+}
+
+func mustCreateTenant(t *testing.T, repos Repos, name string) models.Tenant {
+	t.Helper()
+	tenant := models.Tenant{Name: name}
+	if err := repos.Tenants.Create(context.Background(), &tenant); err != nil {
+		t.Fatalf("create tenant %s: %v", name, err)
+	}
+	return tenant
+}
+
+func mustCreateUser(t *testing.T, repos Repos, tenantID, email string, role models.Role) models.User {
+	t.Helper()
+	user := models.User{Name: email, Email: email, Password: "x", TenantID: tenantID, Role: role}
+	if err := repos.Users.Create(context.Background(), &user); err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user
+}
+
+func TestTenants(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		for _, name := range []string{"Charlie", "Alpha", "Bravo"} {
+			mustCreateTenant(t, b.repos, name)
+		}
+		if err := b.repos.Tenants.Create(ctx, &models.Tenant{Name: "Alpha"}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate create err = %v, want ErrDuplicate", err)
+		}
+		if taken, err := b.repos.Tenants.NameTaken(ctx, "Bravo"); err != nil || !taken {
+			t.Errorf("NameTaken(Bravo) = %v, %v", taken, err)
+		}
+		if _, err := b.repos.Tenants.Get(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get unknown err = %v, want ErrNotFound", err)
+		}
+
+		var names []string
+		opts := db.ListOptions{Limit: 2}
+		for {
+			page, err := b.repos.Tenants.List(ctx, nameSpec, opts)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if page.Total != 3 {
+				t.Errorf("total = %d, want 3", page.Total)
+			}
+			for _, tenant := range page.Items {
+				names = append(names, tenant.Name)
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			opts.Cursor = *page.NextCursor
+		}
+		if len(names) != 3 || names[0] != "Alpha" || names[1] != "Bravo" || names[2] != "Charlie" {
+			t.Errorf("listed %v, want [Alpha Bravo Charlie]", names)
+		}
+	})
+}
+
+func TestUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		other := mustCreateTenant(t, b.repos, "B")
+		admin := mustCreateUser(t, b.repos, a.ID, "admin@a.test", models.RoleAdmin)
+		member := mustCreateUser(t, b.repos, a.ID, "member@a.test", models.RoleMember)
+		outsider := mustCreateUser(t, b.repos, other.ID, "member@b.test", models.RoleMember)
+
+		if err := b.repos.Users.Create(ctx, &models.User{Email: "admin@a.test", Password: "x", TenantID: a.ID}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate create err = %v, want ErrDuplicate", err)
+		}
+		if taken, err := b.repos.Users.EmailTaken(ctx, "ADMIN@a.test"); err != nil || !taken {
+			t.Errorf("EmailTaken ignoring case = %v, %v", taken, err)
+		}
+
+		got, err := b.repos.Users.Member(ctx, a.ID, admin.ID)
+		if err != nil || got.Role != models.RoleAdmin || got.TenantID != a.ID {
+			t.Fatalf("Member = %+v, %v", got, err)
+		}
+		if _, err := b.repos.Users.Member(ctx, a.ID, outsider.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("member of another tenant err = %v, want ErrNotFound", err)
+		}
+		if n, err := b.repos.Users.CountMembers(ctx, a.ID, []string{admin.ID, member.ID, outsider.ID}); err != nil || n != 2 {
+			t.Errorf("CountMembers = %d, %v, want 2", n, err)
+		}
+
+		page, err := b.repos.Users.List(ctx, a.ID, UserFilter{Role: models.RoleMember}, nameSpec, db.ListOptions{})
+		if err != nil || page.Total != 1 || page.Items[0].ID != member.ID {
+			t.Fatalf("members with role MEMBER = %+v, %v", page, err)
+		}
+
+		if err := b.repos.Users.UpdateRole(ctx, a.ID, admin.ID, models.RoleModerator); err != nil {
+			t.Fatalf("update role: %v", err)
+		}
+		got.Name = "Renamed"
+		if err := b.repos.Users.UpdateAccount(ctx, got); err != nil {
+			t.Fatalf("update account: %v", err)
+		}
+		got, err = b.repos.Users.Member(ctx, a.ID, admin.ID)
+		if err != nil || got.Role != models.RoleModerator || got.Name != "Renamed" {
+			t.Errorf("after update = %+v, %v", got, err)
+		}
+		got.Email = member.Email
+		if err := b.repos.Users.UpdateAccount(ctx, got); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("update to a used email err = %v, want ErrDuplicate", err)
+		}
+
+		tenants, err := b.repos.Users.Tenants(ctx, outsider.ID)
+		if err != nil || len(tenants) != 1 || tenants[0].ID != other.ID || tenants[0].Role != models.RoleMember {
+			t.Errorf("Tenants = %+v, %v", tenants, err)
+		}
+	})
+}
+
+func TestChannelVisibility(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		other := mustCreateTenant(t, b.repos, "B")
+		alice := mustCreateUser(t, b.repos, a.ID, "alice@a.test", models.RoleMember)
+		bob := mustCreateUser(t, b.repos, a.ID, "bob@a.test", models.RoleMember)
+
+		create := func(tenantID, name string, typ models.ChannelType) models.Channel {
+			channel := models.Channel{Name: name, StreamID: "s-" + name, TenantID: tenantID, CreatedBy: alice.ID, Type: typ}
+			if err := b.repos.Channels.Create(ctx, &channel); err != nil {
+				t.Fatalf("create channel %s: %v", name, err)
+			}
+			return channel
+		}
+		general := create(a.ID, "general", models.ChannelPublic)
+		secret := create(a.ID, "secret", models.ChannelPrivate)
+		foreign := create(other.ID, "foreign", models.ChannelPublic)
+		b.join(t, secret.ID, alice.ID)
+
+		if _, err := b.repos.Channels.Get(ctx, a.ID, foreign.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("channel of another tenant err = %v, want ErrNotFound", err)
+		}
+		if got, err := b.repos.Channels.GetByStreamID(ctx, a.ID, general.StreamID); err != nil || got.ID != general.ID {
+			t.Errorf("GetByStreamID = %+v, %v", got, err)
+		}
+
+		for _, tc := range []struct {
+			user models.User
+			want []string
+		}{
+			{alice, []string{"general", "secret"}},
+			{bob, []string{"general"}},
+		} {
+			page, err := b.repos.Channels.List(ctx, a.ID, ChannelFilter{VisibleTo: tc.user.ID}, nameSpec, db.ListOptions{})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			var names []string
+			for _, ch := range page.Items {
+				names = append(names, ch.Name)
+			}
+			if len(names) != len(tc.want) || (len(names) == 2 && names[1] != "secret") {
+				t.Errorf("%s sees %v, want %v", tc.user.Email, names, tc.want)
+			}
+
+			ids, err := b.repos.Channels.VisibleStreamIDs(ctx, a.ID, tc.user.ID)
+			sort.Strings(ids)
+			if err != nil || len(ids) != len(tc.want) || ids[0] != general.StreamID {
+				t.Errorf("%s can read %v, %v", tc.user.Email, ids, err)
+			}
+		}
+	})
+}
+
+func TestListDeletedTenantsOfMember(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		mine := mustCreateTenant(t, b.repos, "Mine")
+		theirs := mustCreateTenant(t, b.repos, "Theirs")
+		user := mustCreateUser(t, b.repos, mine.ID, "me@mine.test", models.RoleAdmin)
+		mustCreateUser(t, b.repos, theirs.ID, "them@theirs.test", models.RoleAdmin)
+		for _, tenant := range []models.Tenant{mine, theirs} {
+			if err := b.deleteTenant(tenant.ID); err != nil {
+				t.Fatalf("delete tenant: %v", err)
+			}
+		}
+
+		page, err := b.repos.Tenants.ListDeleted(ctx, user.ID, time.Now().Add(-time.Hour), nameSpec, db.ListOptions{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 1 || page.Items[0].ID != mine.ID {
+			t.Errorf("deleted tenants = %+v, want only %s", page.Items, mine.Name)
+		}
+	})
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		mustCreateUser(t, b.repos, a.ID, "taken@a.test", models.RoleAdmin)
+
+		err := b.repos.Transaction(ctx, func(repos Repos) error {
+			tenant := models.Tenant{Name: "New"}
+			if err := repos.Tenants.Create(ctx, &tenant); err != nil {
+				return err
+			}
+			return repos.Users.Create(ctx, &models.User{Email: "taken@a.test", Password: "x", TenantID: tenant.ID})
+		})
+		if !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("err = %v, want ErrDuplicate", err)
+		}
+		if taken, err := b.repos.Tenants.NameTaken(ctx, "New"); err != nil || taken {
+			t.Errorf("tenant of the failed transaction exists: %v, %v", taken, err)
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		other := mustCreateTenant(t, b.repos, "B")
+		support := models.TenantRole{ID: "11111111-1111-1111-1111-111111111111", TenantID: a.ID, Name: "Support"}
+		b.seed(t, &support)
+		b.seed(t, &models.TenantRole{TenantID: a.ID, Name: "Billing"})
+		b.seed(t, &models.TenantRole{TenantID: other.ID, Name: "Auditor"})
+
+		roles, err := b.repos.Roles.List(ctx, a.ID)
+		if err != nil || len(roles) != 2 || roles[0].Name != "Billing" || roles[1].Name != "Support" {
+			t.Fatalf("List = %+v, %v", roles, err)
+		}
+		if _, err := b.repos.Roles.Get(ctx, other.ID, support.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get from another tenant err = %v, want ErrNotFound", err)
+		}
+		support.Description = "Answers tickets"
+		if err := b.repos.Roles.UpdateDescription(ctx, &support); err != nil {
+			t.Fatal(err)
+		}
+		got, err := b.repos.Roles.Get(ctx, a.ID, support.ID)
+		if err != nil || got.Description != "Answers tickets" {
+			t.Errorf("Get after update = %+v, %v", got, err)
+		}
+	})
+}
+
+func TestInvites(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		other := mustCreateTenant(t, b.repos, "B")
+		now := time.Now()
+		newest := models.Invite{ID: "22222222-2222-2222-2222-222222222222", TenantID: a.ID, Email: "new@a.test", Role: models.RoleMember,
+			ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+		b.seed(t, &newest)
+		b.seed(t, &models.Invite{TenantID: a.ID, Email: "old@a.test", Role: models.RoleMember,
+			ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(-time.Hour)})
+		b.seed(t, &models.Invite{TenantID: a.ID, Email: "expired@a.test", Role: models.RoleMember,
+			ExpiresAt: now.Add(-time.Minute), CreatedAt: now.Add(-2 * time.Hour)})
+
+		invites, err := b.repos.Invites.ListPending(ctx, a.ID)
+		if err != nil || len(invites) != 2 || invites[0].Email != "new@a.test" || invites[1].Email != "old@a.test" {
+			t.Fatalf("ListPending = %+v, %v", invites, err)
+		}
+		if err := b.repos.Invites.Revoke(ctx, other.ID, newest.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Revoke from another tenant err = %v, want ErrNotFound", err)
+		}
+		if err := b.repos.Invites.Revoke(ctx, a.ID, newest.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.repos.Invites.Revoke(ctx, a.ID, newest.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second Revoke err = %v, want ErrNotFound", err)
+		}
+		if invites, _ := b.repos.Invites.ListPending(ctx, a.ID); len(invites) != 1 {
+			t.Errorf("pending after revoke = %+v", invites)
+		}
+	})
+}
+
+func TestAttachments(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		att := models.Attachment{ID: "33333333-3333-3333-3333-333333333333", TenantID: a.ID, ChannelID: "messaging:general",
+			UploaderID: "u", Filename: "a.txt", ContentType: "text/plain", Size: 1, StorageKey: "k"}
+		b.seed(t, &att)
+		if got, err := b.repos.Attachments.Get(ctx, att.ID); err != nil || got.Filename != "a.txt" {
+			t.Errorf("Get = %+v, %v", got, err)
+		}
+
+		if _, err := b.repos.Attachments.Policy(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Policy before save err = %v, want ErrNotFound", err)
+		}
+		for _, max := range []int64{100, 200} {
+			policy := models.AttachmentPolicy{TenantID: a.ID, MaxBytes: max, AllowedTypes: "image/*"}
+			if err := b.repos.Attachments.SavePolicy(ctx, &policy); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if policy, err := b.repos.Attachments.Policy(ctx, a.ID); err != nil || policy.MaxBytes != 200 {
+			t.Errorf("Policy = %+v, %v", policy, err)
+		}
+	})
+}
+
+func TestMentions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		a := mustCreateTenant(t, b.repos, "A")
+		other := mustCreateTenant(t, b.repos, "B")
+		now := time.Now()
+		for i, m := range []models.Mention{
+			{TenantID: a.ID, UserID: "u1", MessageID: "m1", ChannelID: "c1", Kind: models.MentionUser},
+			{TenantID: a.ID, UserID: "u1", MessageID: "m2", ChannelID: "c2", Kind: models.MentionHere},
+			{TenantID: a.ID, UserID: "u1", MessageID: "m3", ChannelID: "c1", Kind: models.MentionChannel},
+			{TenantID: a.ID, UserID: "u2", MessageID: "m1", ChannelID: "c1", Kind: models.MentionUser},
+			{TenantID: other.ID, UserID: "u1", MessageID: "m4", ChannelID: "c1", Kind: models.MentionUser},
+		} {
+			m.AuthorID = "author"
+			m.CreatedAt = now.Add(time.Duration(i) * time.Second)
+			b.seed(t, &m)
+		}
+		spec := db.ListSpec{
+			Sorts:       map[string]db.SortField{"created_at": {Column: "created_at", Time: true}},
+			DefaultSort: "-created_at",
+		}
+
+		page, err := b.repos.Mentions.List(ctx, a.ID, "u1", MentionFilter{}, spec, db.ListOptions{})
+		if err != nil || page.Total != 3 || page.Items[0].MessageID != "m3" {
+			t.Fatalf("List = %+v, %v", page, err)
+		}
+		page, err = b.repos.Mentions.List(ctx, a.ID, "u1", MentionFilter{ChannelID: "c1"}, spec, db.ListOptions{})
+		if err != nil || page.Total != 2 {
+			t.Errorf("List by channel = %+v, %v", page, err)
+		}
+		page, err = b.repos.Mentions.List(ctx, a.ID, "u1", MentionFilter{ChannelID: "c1", Kind: models.MentionUser}, spec, db.ListOptions{})
+		if err != nil || page.Total != 1 || page.Items[0].MessageID != "m1" {
+			t.Errorf("List by channel and kind = %+v, %v", page, err)
+		}
+	})
+}
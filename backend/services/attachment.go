@@ -0,0 +1,160 @@
+// services/attachment.go - File attachments, upload limits and signed download URLs
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAttachmentMaxBytes is the upload limit of tenants without a policy
+	DefaultAttachmentMaxBytes = 10 << 20
+	// MaxAttachmentBytes is the largest upload limit a tenant may configure
+	MaxAttachmentBytes = 100 << 20
+	// DefaultAttachmentTypes are the MIME types tenants without a policy accept
+	DefaultAttachmentTypes = "image/*,application/pdf,text/plain"
+
+	defaultAttachmentURLTTL = time.Hour
+)
+
+// ErrInvalidAttachments is returned when attachments referenced by a message
+// are unknown, were uploaded by someone else or to another channel, or are already sent
+var ErrInvalidAttachments = errors.New("invalid attachments")
+
+// AttachmentURLTTL is read from ATTACHMENT_URL_TTL (e.g. "1h"), default 1 hour
+func AttachmentURLTTL() time.Duration {
+	return durationEnv("ATTACHMENT_URL_TTL", defaultAttachmentURLTTL)
+}
+
+// SaveAttachment stores the contents of att read from r in the blob store
+// and records att as a pending attachment
+func SaveAttachment(ctx context.Context, att *models.Attachment, r io.Reader) error {
+	if att.ID == "" {
+		// The ID is part of the storage key, so assign it before the upload
+		att.ID = uuid.NewString()
+	}
+	att.StorageKey = att.TenantID + "/" + att.ID
+	if err := Blobs().Put(ctx, att.StorageKey, r, att.Size, att.ContentType); err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Create(att).Error; err != nil {
+		Blobs().Delete(ctx, att.StorageKey)
+		return err
+	}
+	return nil
+}
+
+// PendingAttachments returns the attachments with the given IDs, which
+// uploaderID must have uploaded to channelID and not yet sent
+func PendingAttachments(ctx context.Context, ids []string, uploaderID, channelID string) ([]models.Attachment, error) {
+	var atts []models.Attachment
+	err := db.DB.WithContext(ctx).
+		Where("id IN ? AND uploader_id = ? AND channel_id = ? AND message_id IS NULL", ids, uploaderID, channelID).
+		Find(&atts).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(atts) != len(ids) {
+		return nil, ErrInvalidAttachments
+	}
+	return atts, nil
+}
+
+// LinkAttachments references the attachments from msg and sets msg.Attachments
+func LinkAttachments(ctx context.Context, msg *models.Message, atts []models.Attachment) error {
+	if len(atts) == 0 {
+		return nil
+	}
+	ids := make([]string, len(atts))
+	for i := range atts {
+		ids[i] = atts[i].ID
+		atts[i].MessageID = &msg.ID
+	}
+	err := db.DB.WithContext(ctx).Model(&models.Attachment{}).Where("id IN ?", ids).Update("message_id", msg.ID).Error
+	if err != nil {
+		return err
+	}
+	msg.Attachments = signAttachments(atts)
+	return nil
+}
+
+// AttachAttachments fills the Attachments of messages, with signed download
+// URLs. Deleted messages keep no attachments.
+func AttachAttachments(ctx context.Context, messages []models.Message) error {
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if m.DeletedAt == nil {
+			ids = append(ids, m.ID)
+		}
+	}
+	byMessage := make(map[string][]models.Attachment)
+	if len(ids) > 0 {
+		var atts []models.Attachment
+		if err := db.DB.WithContext(ctx).Where("message_id IN ?", ids).Order("created_at").Find(&atts).Error; err != nil {
+			return err
+		}
+		for _, a := range atts {
+			byMessage[*a.MessageID] = append(byMessage[*a.MessageID], a)
+		}
+	}
+	for i := range messages {
+		messages[i].Attachments = signAttachments(byMessage[messages[i].ID])
+	}
+	return nil
+}
+
+func signAttachments(atts []models.Attachment) []models.Attachment {
+	if atts == nil {
+		return []models.Attachment{}
+	}
+	expires := time.Now().Add(AttachmentURLTTL())
+	for i := range atts {
+		atts[i].URL = AttachmentURL(&atts[i], expires)
+	}
+	return atts
+}
+
+// AttachmentURL returns the download path of att, signed until expires
+func AttachmentURL(att *models.Attachment, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	q := url.Values{"expires": {exp}, "signature": {attachmentSignature(att, exp)}}
+	return fmt.Sprintf("/attachments/%s/download?%s", att.ID, q.Encode())
+}
+
+// VerifyAttachmentURL checks the expires and signature parameters of a download URL for att
+func VerifyAttachmentURL(att *models.Attachment, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(attachmentSignature(att, expires)))
+}
+
+// attachmentSignature binds a download URL to the attachment and its tenant
+func attachmentSignature(att *models.Attachment, expires string) string {
+	secret := os.Getenv("ATTACHMENT_URL_SECRET")
+	if secret == "" {
+		secret = string(jwtSecret())
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(att.TenantID + ":" + att.ID + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OpenAttachment returns the contents of att from the blob store
+func OpenAttachment(ctx context.Context, att *models.Attachment) (io.ReadCloser, error) {
+	return Blobs().Open(ctx, att.StorageKey)
+}
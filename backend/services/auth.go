@@ -0,0 +1,302 @@
+// services/auth.go - Access/refresh token issuance and revocation
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidToken is returned for unknown, expired or revoked tokens
+var ErrInvalidToken = errors.New("invalid or revoked token")
+
+// ErrUserDeactivated is returned for deleted users, users removed from the
+// tenant they sign in to, and users of deleted tenants
+var ErrUserDeactivated = errors.New("user is deactivated")
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Token types, in the "typ" claim. Only access tokens are accepted by the
+// REST API; chat tokens are signed with the same secret but are for the
+// chat client only.
+const (
+	tokenTypeAccess = "access"
+	tokenTypeChat   = "chat"
+)
+
+// TokenPair is a short-lived access token and the refresh token used to renew it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token lifetime in seconds
+}
+
+// AccessTokenTTL is read from ACCESS_TOKEN_TTL (e.g. "15m"), default 15 minutes
+func AccessTokenTTL() time.Duration {
+	return durationEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL is read from REFRESH_TOKEN_TTL (e.g. "720h"), default 30 days
+func RefreshTokenTTL() time.Duration {
+	return durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func jwtSecret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// IssueTokens creates an access token and a new refresh token for user,
+// scoped to user.TenantID with user.Role
+func IssueTokens(ctx context.Context, user models.User) (*TokenPair, error) {
+	return issueTokens(db.DB.WithContext(ctx), user)
+}
+
+// IssueTenantTokens issues tokens scoped to tenantID, where user must be an
+// active member; user.TenantID and user.Role are set from the membership
+func IssueTenantTokens(ctx context.Context, user *models.User, tenantID string) (*TokenPair, error) {
+	tx := db.DB.WithContext(ctx)
+	if err := loadMembership(tx, user, tenantID); err != nil {
+		return nil, err
+	}
+	return issueTokens(tx, *user)
+}
+
+func issueTokens(tx *gorm.DB, user models.User) (*TokenPair, error) {
+	access, err := signAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := newRefreshToken(tx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+func signAccessToken(user models.User) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":   user.ID,
+		"email":     user.Email,
+		"role":      user.Role,
+		"tenant_id": user.TenantID,
+		"ver":       user.TokenVersion,
+		"typ":       tokenTypeAccess,
+		"jti":       uuid.New().String(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(AccessTokenTTL()).Unix(),
+	})
+	return token.SignedString(jwtSecret())
+}
+
+// newRefreshToken stores the hash of a random token and returns the token itself
+func newRefreshToken(tx *gorm.DB, user models.User) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	row := models.RefreshToken{
+		UserID:    user.ID,
+		TenantID:  user.TenantID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokens rotates a refresh token: the presented token is revoked and a new pair issued.
+// Presenting an already rotated token revokes every session of its user.
+func RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := ""
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		if row.RevokedAt != nil {
+			reused = row.UserID
+			return ErrInvalidToken
+		}
+		if time.Now().After(row.ExpiresAt) {
+			return ErrInvalidToken
+		}
+		// Revoke first and only if still active: of two concurrent refreshes
+		// with the same token, the loser matches no row and counts as reuse
+		revoke := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", row.ID).
+			Update("revoked_at", time.Now())
+		if revoke.Error != nil {
+			return revoke.Error
+		}
+		if revoke.RowsAffected == 0 {
+			reused = row.UserID
+			return ErrInvalidToken
+		}
+		var user models.User
+		if err := tx.Unscoped().First(&user, "id = ?", row.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		if err := loadMembership(tx, &user, row.TenantID); err != nil {
+			return err
+		}
+		var err error
+		pair, err = issueTokens(tx, user)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&row).Update("replaced_by", hashToken(pair.RefreshToken)).Error
+	})
+	if reused != "" {
+		if rerr := RevokeUserTokens(ctx, reused); rerr != nil {
+			return nil, rerr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RevokeRefreshToken revokes one of userID's refresh tokens; unknown tokens are ignored
+func RevokeRefreshToken(ctx context.Context, userID, refreshToken string) error {
+	return db.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND token_hash = ? AND revoked_at IS NULL", userID, hashToken(refreshToken)).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken denylists an access token by its jti until it expires
+func RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	tx := db.DB.WithContext(ctx)
+	// Expired entries can no longer match a valid token
+	if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return tx.Save(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// RevokeUserTokens invalidates every access and refresh token of a user,
+// e.g. after logout from all devices, deletion or a role change
+func RevokeUserTokens(ctx context.Context, userID string) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// loadMembership copies user's role in tenantID to user. It returns
+// ErrUserDeactivated unless the account, its membership and the tenant are
+// all active.
+func loadMembership(tx *gorm.DB, user *models.User, tenantID string) error {
+	if user.DeletedAt.Valid {
+		return ErrUserDeactivated
+	}
+	var member models.TenantMember
+	err := tx.Joins("JOIN tenants ON tenants.id = tenant_members.tenant_id AND tenants.deleted_at IS NULL").
+		Where("tenant_members.tenant_id = ? AND tenant_members.user_id = ? AND tenant_members.removed_at IS NULL", tenantID, user.ID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserDeactivated
+	}
+	if err != nil {
+		return err
+	}
+	user.TenantID = member.TenantID
+	user.Role = member.Role
+	return nil
+}
+
+// ParseAccessToken verifies an access token's signature, type and expiry and
+// rejects it if it was denylisted or its user's token version has changed
+func ParseAccessToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtSecret(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["user_id"].(string)
+	tenantID, _ := claims["tenant_id"].(string)
+	version, _ := claims["ver"].(float64)
+	jti, _ := claims["jti"].(string)
+	if typ, _ := claims["typ"].(string); typ != tokenTypeAccess || jti == "" {
+		return nil, ErrInvalidToken
+	}
+
+	tx := db.DB.WithContext(ctx)
+	var user models.User
+	err = tx.Unscoped().Select("id", "token_version", "deleted_at").First(&user, "id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if err := loadMembership(tx, &user, tenantID); err != nil {
+		return nil, err
+	}
+	if user.TokenVersion != int(version) {
+		return nil, ErrInvalidToken
+	}
+	var revoked int64
+	if err := tx.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&revoked).Error; err != nil {
+		return nil, err
+	}
+	if revoked > 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -1,11 +1,166 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/Tabintel/multi-tenant-chat/backend/db"
 	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SaveChannel persists a channel to the database
 func SaveChannel(channel models.Channel) error {
 	return db.DB.Create(&channel).Error
 }
+
+// UpdateChannel saves the channel's name and description and syncs them to the chat provider
+func UpdateChannel(ctx context.Context, channel *models.Channel) error {
+	if err := Chat().UpdateChannel(ctx, channel.StreamID, *channel); err != nil {
+		return err
+	}
+	return db.DB.WithContext(ctx).Model(channel).
+		Updates(map[string]interface{}{"name": channel.Name, "description": channel.Description}).Error
+}
+
+// ArchiveChannel makes a channel read-only; the provider channel is frozen
+func ArchiveChannel(ctx context.Context, channel *models.Channel) error {
+	now := time.Now()
+	archived := *channel
+	archived.ArchivedAt = &now
+	if err := Chat().UpdateChannel(ctx, channel.StreamID, archived); err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Model(channel).Update("archived_at", now).Error; err != nil {
+		return err
+	}
+	channel.ArchivedAt = &now
+	return nil
+}
+
+// DeleteChannel soft-deletes a channel and hides it in the chat provider
+func DeleteChannel(ctx context.Context, channel *models.Channel) error {
+	if err := Chat().DeleteChannel(ctx, channel.StreamID); err != nil {
+		return err
+	}
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Free the DM key so the same participants can start a new conversation
+		if channel.DMKey != nil {
+			if err := tx.Model(channel).Update("dm_key", nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(channel).Error
+	})
+}
+
+// RestoreChannel undoes DeleteChannel within the grace period. Restored
+// conversations are no longer reused for their participants.
+func RestoreChannel(ctx context.Context, channel *models.Channel) error {
+	if err := checkRestorable(channel.DeletedAt.Time); err != nil {
+		return err
+	}
+	if err := Chat().RestoreChannel(ctx, channel.StreamID); err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Unscoped().Model(channel).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	channel.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+// ChannelMemberRole returns userID's role in the channel, or "" if they are not a member
+func ChannelMemberRole(ctx context.Context, channelID, userID string) (models.ChannelRole, error) {
+	var member models.ChannelMember
+	err := db.DB.WithContext(ctx).First(&member, "channel_id = ? AND user_id = ?", channelID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// IsChannelMember reports whether userID is a member of the channel
+func IsChannelMember(ctx context.Context, channelID, userID string) (bool, error) {
+	role, err := ChannelMemberRole(ctx, channelID, userID)
+	return role != "", err
+}
+
+// AddChannelMembers adds users to a channel with the given channel role and
+// mirrors the membership to the chat provider. Existing members keep their role.
+func AddChannelMembers(ctx context.Context, channel models.Channel, userIDs []string, role models.ChannelRole) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	tx := db.DB.WithContext(ctx)
+	var existing []string
+	err := tx.Model(&models.ChannelMember{}).Where("channel_id = ? AND user_id IN ?", channel.ID, userIDs).
+		Pluck("user_id", &existing).Error
+	if err != nil {
+		return err
+	}
+	skip := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		skip[id] = true
+	}
+	var added []string
+	var members []models.ChannelMember
+	for _, id := range userIDs {
+		if !skip[id] {
+			added = append(added, id)
+			members = append(members, models.ChannelMember{ChannelID: channel.ID, UserID: id, Role: role})
+		}
+	}
+	if len(members) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+			return err
+		}
+	}
+	// The provider may share the database connection, so it is called outside
+	// a transaction and the new rows are rolled back by hand if it fails
+	if err := Chat().AddMembers(ctx, channel.StreamID, userIDs); err != nil {
+		if len(added) > 0 {
+			tx.Where("channel_id = ? AND user_id IN ?", channel.ID, added).Delete(&models.ChannelMember{})
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveChannelMembers removes users from a channel and from the chat provider
+func RemoveChannelMembers(ctx context.Context, channel models.Channel, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := Chat().RemoveMembers(ctx, channel.StreamID, userIDs); err != nil {
+		return err
+	}
+	return db.DB.WithContext(ctx).Where("channel_id = ? AND user_id IN ?", channel.ID, userIDs).
+		Delete(&models.ChannelMember{}).Error
+}
+
+// ChannelMemberInfo is a channel member joined with their user record
+type ChannelMemberInfo struct {
+	UserID   string             `json:"user_id"`
+	Name     string             `json:"name"`
+	Email    string             `json:"email"`
+	Role     models.ChannelRole `json:"role"`
+	JoinedAt time.Time          `json:"joined_at"`
+}
+
+// ListChannelMembers returns the members of a channel ordered by join time
+func ListChannelMembers(ctx context.Context, channelID string) ([]ChannelMemberInfo, error) {
+	var members []ChannelMemberInfo
+	err := db.DB.WithContext(ctx).Model(&models.ChannelMember{}).
+		Select("channel_members.user_id, users.name, users.email, channel_members.role, channel_members.joined_at").
+		Joins("JOIN users ON users.id = channel_members.user_id").
+		Where("channel_members.channel_id = ?", channelID).
+		Order("channel_members.joined_at, users.name").
+		Scan(&members).Error
+	return members, err
+}
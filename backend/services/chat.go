@@ -0,0 +1,215 @@
+// services/chat.go - Pluggable chat backend
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrChannelNotFound is returned when a chat channel does not exist in the provider
+	ErrChannelNotFound = errors.New("chat channel not found")
+	// ErrChannelFrozen is returned when sending to an archived (frozen) channel
+	ErrChannelFrozen = errors.New("chat channel is frozen")
+	// ErrMessageNotFound is returned when a message does not exist in the provider
+	ErrMessageNotFound = errors.New("chat message not found")
+	// ErrInvalidCursor is returned when a message cursor does not name a message of the channel
+	ErrInvalidCursor = errors.New("invalid message cursor")
+)
+
+const (
+	// DefaultMessageLimit matches the default batch size returned by Stream
+	DefaultMessageLimit = 25
+	// MaxMessageLimit is the largest page GetMessages returns
+	MaxMessageLimit = 100
+)
+
+// MessageInput is a message to send
+type MessageInput struct {
+	UserID   string
+	Text     string
+	ParentID string // top-level message to reply to, empty for a new message
+	// Attachments are already stored; providers only reference them
+	Attachments []models.Attachment
+	// MentionedUserIDs are the users the message mentions
+	MentionedUserIDs []string
+}
+
+// MessageQuery selects a page of messages. Cursors are exclusive and may be
+// combined; without an After cursor the newest matching messages are returned.
+type MessageQuery struct {
+	Limit    int // DefaultMessageLimit if zero
+	BeforeID string
+	AfterID  string
+	Before   *time.Time
+	After    *time.Time
+}
+
+// MessagePage is a page of messages, oldest first
+type MessagePage struct {
+	Messages []models.Message
+	HasOlder bool // more messages exist before Messages[0]
+	HasNewer bool // more messages exist after the last message
+}
+
+func (q MessageQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultMessageLimit
+	}
+	if q.Limit > MaxMessageLimit {
+		return MaxMessageLimit
+	}
+	return q.Limit
+}
+
+// forward reports whether the page starts at an After cursor and moves towards newer messages
+func (q MessageQuery) forward() bool {
+	return (q.AfterID != "" || q.After != nil) && q.BeforeID == "" && q.Before == nil
+}
+
+// newMessagePage builds a page from messages fetched oldest first with one
+// extra message beyond the limit in the paging direction
+func newMessagePage(messages []models.Message, q MessageQuery) *MessagePage {
+	limit := q.limit()
+	page := &MessagePage{Messages: messages}
+	if q.forward() {
+		page.HasOlder = true
+		if len(messages) > limit {
+			page.Messages = messages[:limit]
+			page.HasNewer = true
+		}
+		return page
+	}
+	page.HasNewer = q.BeforeID != "" || q.Before != nil
+	if len(messages) > limit {
+		page.Messages = messages[len(messages)-limit:]
+		page.HasOlder = true
+	}
+	return page
+}
+
+// SearchQuery selects the messages matched by a full-text search.
+// Results are limited to ChannelIDs, which must not be empty.
+type SearchQuery struct {
+	Text          string
+	ChannelIDs    []string // Stream channel IDs the caller may read
+	UserID        string   // only messages by this author
+	Before        *time.Time
+	After         *time.Time
+	HasAttachment bool
+	Limit         int
+	Cursor        string // Next of the previous SearchPage
+}
+
+func (q SearchQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultMessageLimit
+	}
+	if q.Limit > MaxMessageLimit {
+		return MaxMessageLimit
+	}
+	return q.Limit
+}
+
+// SearchPage is one page of search results, best matches first.
+// Next is passed as SearchQuery.Cursor for the following page; it is empty on the last page.
+type SearchPage struct {
+	Messages []models.Message
+	Next     string
+}
+
+// ChatProvider is implemented by every chat backend (Stream, local).
+// Channel IDs are the provider's IDs, stored as models.Channel.StreamID.
+type ChatProvider interface {
+	// UpsertUser creates or updates the chat-side user
+	UpsertUser(ctx context.Context, user models.User) error
+	// DeactivateUser stops userID from connecting; their messages are kept
+	DeactivateUser(ctx context.Context, userID string) error
+	// ReactivateUser undoes DeactivateUser
+	ReactivateUser(ctx context.Context, userID string) error
+	// CreateChannel creates a channel with the creator as its first member and returns its ID
+	CreateChannel(ctx context.Context, channel models.Channel, creatorID string) (string, error)
+	// QueryChannels lists the channels tagged with tenantID
+	QueryChannels(ctx context.Context, tenantID string) ([]models.Channel, error)
+	// UpdateChannel syncs the channel's name, description and archived (frozen) state
+	UpdateChannel(ctx context.Context, channelID string, channel models.Channel) error
+	// DeleteChannel hides a channel from its members; its messages are kept
+	// so RestoreChannel can bring it back
+	DeleteChannel(ctx context.Context, channelID string) error
+	// RestoreChannel makes a deleted channel available again
+	RestoreChannel(ctx context.Context, channelID string) error
+	AddMembers(ctx context.Context, channelID string, userIDs []string) error
+	RemoveMembers(ctx context.Context, channelID string, userIDs []string) error
+	// ListMembers returns the user IDs of the channel members
+	ListMembers(ctx context.Context, channelID string) ([]string, error)
+	SendMessage(ctx context.Context, channelID string, in MessageInput) (*models.Message, error)
+	// GetMessages returns one page of a channel's messages, oldest first
+	GetMessages(ctx context.Context, channelID string, q MessageQuery) (*MessagePage, error)
+	// GetReplies returns one page of the replies to parentID, oldest first
+	GetReplies(ctx context.Context, parentID string, q MessageQuery) (*MessagePage, error)
+	// GetMessage returns a single message, including tombstones
+	GetMessage(ctx context.Context, messageID string) (*models.Message, error)
+	// UpdateMessage replaces the text of a message and marks it as edited
+	UpdateMessage(ctx context.Context, messageID, text string) (*models.Message, error)
+	// DeleteMessage turns a message into a tombstone without text
+	DeleteMessage(ctx context.Context, messageID string) error
+	// SearchMessages runs a full-text search over the messages of q.ChannelIDs; tombstones never match
+	SearchMessages(ctx context.Context, q SearchQuery) (*SearchPage, error)
+	// AddReaction records userID's emoji reaction to a message
+	AddReaction(ctx context.Context, messageID, userID, emoji string) error
+	// RemoveReaction removes userID's emoji reaction from a message
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	// CreateToken issues a client-side token for userID
+	CreateToken(userID string, expiresAt time.Time) (string, error)
+}
+
+var chatProvider ChatProvider
+var chatOnce sync.Once
+
+// Chat returns the configured chat provider.
+// CHAT_PROVIDER selects the backend: "stream" (default) or "local";
+// mock mode always uses the local backend.
+func Chat() ChatProvider {
+	chatOnce.Do(func() {
+		if chatProvider != nil {
+			return
+		}
+		if db.Mock {
+			chatProvider = NewLocalChat(db.DB)
+			return
+		}
+		switch strings.ToLower(os.Getenv("CHAT_PROVIDER")) {
+		case "", "stream":
+			chatProvider = NewStreamChat(GetStreamClient())
+		case "local":
+			chatProvider = NewLocalChat(db.DB)
+		default:
+			log.Fatalf("Unknown CHAT_PROVIDER %q: must be stream or local", os.Getenv("CHAT_PROVIDER"))
+		}
+	})
+	return chatProvider
+}
+
+// SetChatProvider replaces the configured chat provider
+func SetChatProvider(p ChatProvider) {
+	chatOnce.Do(func() {})
+	chatProvider = p
+}
+
+// newChannelID builds a provider channel ID; Stream limits IDs to 64 characters
+func newChannelID(tenantID string) string {
+	shortTenantID := tenantID
+	if len(shortTenantID) > 8 {
+		shortTenantID = shortTenantID[:8]
+	}
+	return shortTenantID + "-" + uuid.New().String()
+}
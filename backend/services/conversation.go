@@ -0,0 +1,84 @@
+// services/conversation.go - Direct messages and group DMs
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+// ConversationKey is the DMKey of the conversation between userIDs in tenantID;
+// it does not depend on the order of the participants
+func ConversationKey(tenantID string, userIDs []string) string {
+	ids := append([]string(nil), userIDs...)
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(tenantID + ":" + strings.Join(ids, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindOrCreateConversation returns the direct conversation between the given
+// participants (creatorID included), creating it if needed. The boolean
+// reports whether a new conversation was created.
+func FindOrCreateConversation(ctx context.Context, tenantID, creatorID string, participantIDs []string) (*models.Channel, bool, error) {
+	key := ConversationKey(tenantID, participantIDs)
+	channel, err := findConversation(ctx, key)
+	if err == nil {
+		return channel, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	channel = &models.Channel{
+		TenantID:  tenantID,
+		CreatedBy: creatorID,
+		Type:      models.ChannelDirect,
+		DMKey:     &key,
+	}
+	streamID, err := Chat().CreateChannel(ctx, *channel, creatorID)
+	if err != nil {
+		return nil, false, err
+	}
+	channel.StreamID = streamID
+	if err := db.DB.WithContext(ctx).Create(channel).Error; err != nil {
+		// Lost a race with a concurrent request for the same participants
+		if existing, ferr := findConversation(ctx, key); ferr == nil {
+			Chat().DeleteChannel(ctx, streamID)
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+	if err := AddChannelMembers(ctx, *channel, participantIDs, models.ChannelRoleMember); err != nil {
+		// Without members nobody could open it, yet its dm_key would keep
+		// the participants from getting a new one
+		db.DB.WithContext(ctx).Unscoped().Delete(channel)
+		Chat().DeleteChannel(ctx, streamID)
+		return nil, false, err
+	}
+	return channel, true, nil
+}
+
+func findConversation(ctx context.Context, key string) (*models.Channel, error) {
+	var channel models.Channel
+	if err := db.DB.WithContext(ctx).First(&channel, "dm_key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// ListConversations returns the direct conversations userID takes part in
+// within tenantID, most recently created first
+func ListConversations(ctx context.Context, tenantID, userID string) ([]models.Channel, error) {
+	var channels []models.Channel
+	memberOf := db.DB.Model(&models.ChannelMember{}).Select("channel_id").Where("user_id = ?", userID)
+	err := db.ForTenant(ctx, tenantID).Where("type = ? AND id IN (?)", models.ChannelDirect, memberOf).
+		Order("created_at DESC").Find(&channels).Error
+	return channels, err
+}
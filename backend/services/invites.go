@@ -0,0 +1,179 @@
+// services/invites.go - Tenant invitations and their signed tokens
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const inviteTokenType = "invite"
+
+var (
+	// ErrInvalidInvite is returned for unknown, tampered, expired, revoked or used invites
+	ErrInvalidInvite = errors.New("invalid or expired invite")
+	// ErrEmailTaken is returned when the invited email already belongs to a member of the tenant
+	ErrEmailTaken = errors.New("email already a member")
+	// ErrWrongPassword is returned when an invite is accepted for an existing
+	// account with a password that is not the account's
+	ErrWrongPassword = errors.New("password does not match the existing account")
+)
+
+// CreateInvite stores a pending invite and returns it with its signed token
+func CreateInvite(ctx context.Context, invite *models.Invite) (string, error) {
+	invite.Email = strings.ToLower(strings.TrimSpace(invite.Email))
+	var count int64
+	err := db.TenantUsers(ctx, invite.TenantID, false).Where("LOWER(users.email) = ?", invite.Email).Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", ErrEmailTaken
+	}
+	if err := db.DB.WithContext(ctx).Create(invite).Error; err != nil {
+		return "", err
+	}
+	return SignInviteToken(*invite)
+}
+
+// SignInviteToken signs a token identifying the invite; it expires with the invite
+func SignInviteToken(invite models.Invite) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"typ":       inviteTokenType,
+		"invite_id": invite.ID,
+		"tenant_id": invite.TenantID,
+		"email":     invite.Email,
+		"exp":       invite.ExpiresAt.Unix(),
+	})
+	return token.SignedString(jwtSecret())
+}
+
+// parseInviteToken verifies the token and returns the invite ID it names
+func parseInviteToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtSecret(), nil
+	})
+	if err != nil || !token.Valid {
+		return "", ErrInvalidInvite
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if typ, _ := claims["typ"].(string); typ != inviteTokenType {
+		return "", ErrInvalidInvite
+	}
+	id, _ := claims["invite_id"].(string)
+	if id == "" {
+		return "", ErrInvalidInvite
+	}
+	return id, nil
+}
+
+// AcceptInvite adds the invited email to the invite's tenant with the invited
+// role and marks the invite as used. An account is created for new emails;
+// existing accounts must present their password and keep their name.
+func AcceptInvite(ctx context.Context, tokenString, name, password string) (*models.User, error) {
+	inviteID, err := parseInviteToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	reactivated := false
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invite models.Invite
+		if err := tx.First(&invite, "id = ?", inviteID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidInvite
+			}
+			return err
+		}
+		now := time.Now()
+		if !invite.IsPending(now) {
+			return ErrInvalidInvite
+		}
+		err := tx.Unscoped().Where("LOWER(email) = ?", invite.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = models.User{
+				Name:     name,
+				Email:    invite.Email,
+				Password: string(hash),
+				Role:     invite.Role,
+				TenantID: invite.TenantID,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if reactivated, err = joinTenant(tx, &user, invite, password); err != nil {
+				return err
+			}
+		}
+		// Only one concurrent accept can flip the invite from pending
+		res := tx.Model(&models.Invite{}).Where("id = ? AND accepted_at IS NULL", invite.ID).Update("accepted_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidInvite
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reactivated {
+		if err := Chat().ReactivateUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// joinTenant makes the existing account user a member of the invite's
+// tenant, restoring a previous membership or a deleted account. It reports
+// whether the account was restored.
+func joinTenant(tx *gorm.DB, user *models.User, invite models.Invite, password string) (bool, error) {
+	if CheckPassword(password, user.Password) != nil {
+		return false, ErrWrongPassword
+	}
+	var member models.TenantMember
+	err := tx.Where("tenant_id = ? AND user_id = ?", invite.TenantID, user.ID).First(&member).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.TenantMember{TenantID: invite.TenantID, UserID: user.ID, Role: invite.Role}
+		err = tx.Create(&member).Error
+	case err != nil:
+	case member.RemovedAt == nil:
+		err = ErrEmailTaken
+	default:
+		err = tx.Model(&member).Updates(map[string]interface{}{"role": invite.Role, "removed_at": nil}).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	user.TenantID = invite.TenantID
+	user.Role = invite.Role
+	if !user.DeletedAt.Valid {
+		return false, nil
+	}
+	if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Update("deleted_at", nil).Error; err != nil {
+		return false, err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	return true, nil
+}
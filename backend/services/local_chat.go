@@ -0,0 +1,395 @@
+// services/local_chat.go - In-process chat backend stored in the application database
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LocalChat is the ChatProvider that keeps channels, members and messages
+// in the application database, so no external chat service is needed
+type LocalChat struct {
+	db *gorm.DB
+}
+
+// NewLocalChat returns a ChatProvider backed by the given database
+func NewLocalChat(db *gorm.DB) *LocalChat {
+	return &LocalChat{db: db}
+}
+
+// CreateToken issues an HS256 token signed with JWT_SECRET. Its "typ" claim
+// keeps the API from accepting it as an access token.
+func (l *LocalChat) CreateToken(userID string, expiresAt time.Time) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("userID is required for token generation")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"typ":     tokenTypeChat,
+		"exp":     expiresAt.Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// UpsertUser is a no-op: local chat users are the rows of the users table
+func (l *LocalChat) UpsertUser(ctx context.Context, user models.User) error {
+	return nil
+}
+
+// DeactivateUser is a no-op: deleted users are rejected by the API itself
+func (l *LocalChat) DeactivateUser(ctx context.Context, userID string) error {
+	return nil
+}
+
+// ReactivateUser is a no-op, see DeactivateUser
+func (l *LocalChat) ReactivateUser(ctx context.Context, userID string) error {
+	return nil
+}
+
+func (l *LocalChat) CreateChannel(ctx context.Context, channel models.Channel, creatorID string) (string, error) {
+	ch := models.ChatChannel{
+		ID:          newChannelID(channel.TenantID),
+		TenantID:    channel.TenantID,
+		Name:        channel.Name,
+		Description: channel.Description,
+		CreatedBy:   creatorID,
+	}
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ch).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ChatMember{ChannelID: ch.ID, UserID: creatorID}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return ch.ID, nil
+}
+
+func (l *LocalChat) QueryChannels(ctx context.Context, tenantID string) ([]models.Channel, error) {
+	var chans []models.ChatChannel
+	if err := l.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("created_at").Find(&chans).Error; err != nil {
+		return nil, err
+	}
+	result := make([]models.Channel, len(chans))
+	for i, ch := range chans {
+		result[i] = models.Channel{
+			StreamID:    ch.ID,
+			Name:        ch.Name,
+			Description: ch.Description,
+			TenantID:    ch.TenantID,
+			CreatedBy:   ch.CreatedBy,
+		}
+	}
+	return result, nil
+}
+
+func (l *LocalChat) UpdateChannel(ctx context.Context, channelID string, channel models.Channel) error {
+	res := l.db.WithContext(ctx).Model(&models.ChatChannel{}).Where("id = ?", channelID).
+		Updates(map[string]interface{}{
+			"name":        channel.Name,
+			"description": channel.Description,
+			"frozen":      channel.ArchivedAt != nil,
+		})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrChannelNotFound
+	}
+	return res.Error
+}
+
+func (l *LocalChat) DeleteChannel(ctx context.Context, channelID string) error {
+	res := l.db.WithContext(ctx).Delete(&models.ChatChannel{}, "id = ?", channelID)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrChannelNotFound
+	}
+	return res.Error
+}
+
+func (l *LocalChat) RestoreChannel(ctx context.Context, channelID string) error {
+	res := l.db.WithContext(ctx).Unscoped().Model(&models.ChatChannel{}).
+		Where("id = ?", channelID).Update("deleted_at", nil)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrChannelNotFound
+	}
+	return res.Error
+}
+
+func (l *LocalChat) AddMembers(ctx context.Context, channelID string, userIDs []string) error {
+	if err := l.requireChannel(ctx, channelID); err != nil {
+		return err
+	}
+	members := make([]models.ChatMember, len(userIDs))
+	for i, id := range userIDs {
+		members[i] = models.ChatMember{ChannelID: channelID, UserID: id}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error
+}
+
+func (l *LocalChat) RemoveMembers(ctx context.Context, channelID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return l.db.WithContext(ctx).
+		Where("channel_id = ? AND user_id IN ?", channelID, userIDs).
+		Delete(&models.ChatMember{}).Error
+}
+
+func (l *LocalChat) ListMembers(ctx context.Context, channelID string) ([]string, error) {
+	if err := l.requireChannel(ctx, channelID); err != nil {
+		return nil, err
+	}
+	var ids []string
+	err := l.db.WithContext(ctx).Model(&models.ChatMember{}).
+		Where("channel_id = ?", channelID).Order("created_at").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (l *LocalChat) SendMessage(ctx context.Context, channelID string, in MessageInput) (*models.Message, error) {
+	var ch models.ChatChannel
+	err := l.db.WithContext(ctx).Select("id", "frozen").First(&ch, "id = ?", channelID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChannelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ch.Frozen {
+		return nil, ErrChannelFrozen
+	}
+	msg := models.Message{ChannelID: channelID, UserID: in.UserID, Text: in.Text, ParentID: in.ParentID}
+	err = l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&msg).Error; err != nil {
+			return err
+		}
+		if msg.ParentID == "" {
+			return nil
+		}
+		res := tx.Model(&models.Message{}).Where("id = ? AND channel_id = ?", msg.ParentID, channelID).
+			Update("reply_count", gorm.Expr("reply_count + 1"))
+		if res.Error == nil && res.RowsAffected == 0 {
+			return ErrMessageNotFound
+		}
+		return res.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (l *LocalChat) GetMessages(ctx context.Context, channelID string, q MessageQuery) (*MessagePage, error) {
+	if err := l.requireChannel(ctx, channelID); err != nil {
+		return nil, err
+	}
+	// Like Stream, the channel shows top-level messages; replies live in their thread
+	return l.pageMessages(ctx, q, func(db *gorm.DB) *gorm.DB {
+		return db.Where("channel_id = ? AND (parent_id IS NULL OR parent_id = '')", channelID)
+	})
+}
+
+func (l *LocalChat) GetReplies(ctx context.Context, parentID string, q MessageQuery) (*MessagePage, error) {
+	if _, err := l.GetMessage(ctx, parentID); err != nil {
+		return nil, err
+	}
+	return l.pageMessages(ctx, q, func(db *gorm.DB) *gorm.DB {
+		return db.Where("parent_id = ?", parentID)
+	})
+}
+
+// pageMessages returns one page of the messages selected by scope
+func (l *LocalChat) pageMessages(ctx context.Context, q MessageQuery, scope func(*gorm.DB) *gorm.DB) (*MessagePage, error) {
+	query := l.db.WithContext(ctx).Scopes(scope)
+	// Messages are ordered by (created_at, id) so equal timestamps page stably
+	if q.BeforeID != "" {
+		cursor, err := l.cursorMessage(ctx, scope, q.BeforeID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	if q.AfterID != "" {
+		cursor, err := l.cursorMessage(ctx, scope, q.AfterID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	if q.Before != nil {
+		query = query.Where("created_at < ?", *q.Before)
+	}
+	if q.After != nil {
+		query = query.Where("created_at > ?", *q.After)
+	}
+	order := "created_at DESC, id DESC"
+	if q.forward() {
+		order = "created_at, id"
+	}
+	var messages []models.Message
+	if err := query.Order(order).Limit(q.limit() + 1).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	if !q.forward() {
+		// Newest were fetched first; return them oldest first like Stream does
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return newMessagePage(messages, q), nil
+}
+
+// cursorMessage loads the message a cursor points at, which must be selected by scope
+func (l *LocalChat) cursorMessage(ctx context.Context, scope func(*gorm.DB) *gorm.DB, messageID string) (*models.Message, error) {
+	var msg models.Message
+	err := l.db.WithContext(ctx).Scopes(scope).Select("id", "created_at").First(&msg, "id = ?", messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (l *LocalChat) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	var msg models.Message
+	err := l.db.WithContext(ctx).First(&msg, "id = ?", messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (l *LocalChat) UpdateMessage(ctx context.Context, messageID, text string) (*models.Message, error) {
+	msg, err := l.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	msg.Text = text
+	msg.EditedAt = &now
+	err = l.db.WithContext(ctx).Model(msg).Updates(map[string]interface{}{"text": text, "edited_at": now}).Error
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// DeleteMessage tombstones a message; deleting a reply takes it out of its
+// parent's reply count
+func (l *LocalChat) DeleteMessage(ctx context.Context, messageID string) error {
+	return l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msg models.Message
+		err := tx.Select("id", "parent_id").First(&msg, "id = ? AND deleted_at IS NULL", messageID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&models.Message{}).Where("id = ? AND deleted_at IS NULL", messageID).
+			Updates(map[string]interface{}{"text": "", "deleted_at": time.Now()})
+		if res.Error == nil && res.RowsAffected == 0 {
+			return ErrMessageNotFound
+		}
+		if res.Error != nil || msg.ParentID == "" {
+			return res.Error
+		}
+		return tx.Model(&models.Message{}).Where("id = ? AND reply_count > 0", msg.ParentID).
+			Update("reply_count", gorm.Expr("reply_count - 1")).Error
+	})
+}
+
+// searchConfig is the Postgres text search configuration used for messages
+const searchConfig = "english"
+
+// SearchMessages matches messages with Postgres full-text search, ranked by
+// relevance; other databases (SQLite in mock mode) match every word as a
+// substring instead. Cursors are result offsets.
+func (l *LocalChat) SearchMessages(ctx context.Context, q SearchQuery) (*SearchPage, error) {
+	offset := 0
+	if q.Cursor != "" {
+		n, err := strconv.Atoi(q.Cursor)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidCursor
+		}
+		offset = n
+	}
+	query := l.db.WithContext(ctx).Model(&models.Message{}).
+		Where("channel_id IN ? AND deleted_at IS NULL", q.ChannelIDs)
+	if q.UserID != "" {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Before != nil {
+		query = query.Where("created_at < ?", *q.Before)
+	}
+	if q.After != nil {
+		query = query.Where("created_at > ?", *q.After)
+	}
+	if q.HasAttachment {
+		query = query.Where("EXISTS (SELECT 1 FROM attachments WHERE attachments.message_id = messages.id)")
+	}
+	// Newest first, or by relevance with newest first among equals. A single
+	// ORDER BY clause, as Gorm merges further Order calls into it.
+	order := clause.Expr{SQL: "created_at DESC, id DESC"}
+	if l.db.Dialector.Name() == "postgres" {
+		if q.Text != "" {
+			query = query.Where("to_tsvector('"+searchConfig+"', text) @@ plainto_tsquery('"+searchConfig+"', ?)", q.Text)
+			order = clause.Expr{
+				SQL:  "ts_rank(to_tsvector('" + searchConfig + "', text), plainto_tsquery('" + searchConfig + "', ?)) DESC, " + order.SQL,
+				Vars: []interface{}{q.Text},
+			}
+		}
+	} else {
+		for _, word := range strings.Fields(strings.ToLower(q.Text)) {
+			query = query.Where("LOWER(text) LIKE ?", "%"+word+"%")
+		}
+	}
+	var messages []models.Message
+	limit := q.limit()
+	err := query.Clauses(clause.OrderBy{Expression: order}).Offset(offset).Limit(limit + 1).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	page := &SearchPage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.Next = strconv.Itoa(offset + limit)
+	}
+	return page, nil
+}
+
+// AddReaction is a no-op: local reactions are the rows of the reactions table
+func (l *LocalChat) AddReaction(ctx context.Context, messageID, userID, emoji string) error {
+	return nil
+}
+
+// RemoveReaction is a no-op: local reactions are the rows of the reactions table
+func (l *LocalChat) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+	return nil
+}
+
+// requireChannel returns ErrChannelNotFound unless channelID exists
+func (l *LocalChat) requireChannel(ctx context.Context, channelID string) error {
+	var ch models.ChatChannel
+	err := l.db.WithContext(ctx).Select("id").First(&ch, "id = ?", channelID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrChannelNotFound
+	}
+	return err
+}
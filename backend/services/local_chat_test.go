@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestSearchMessagesOrdersByRank checks the SQL of the Postgres search, which
+// the sqlite tests never run
+func TestSearchMessagesOrdersByRank(t *testing.T) {
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sql string
+	err = conn.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewLocalChat(conn).SearchMessages(context.Background(), SearchQuery{Text: "launch", ChannelIDs: []string{"c1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ORDER BY ts_rank(to_tsvector('english', text), plainto_tsquery('english', $3)) DESC, created_at DESC, id DESC"
+	if !strings.Contains(sql, want) {
+		t.Errorf("query = %s\nwant it to contain %s", sql, want)
+	}
+
+	if _, err := NewLocalChat(conn).SearchMessages(context.Background(), SearchQuery{ChannelIDs: []string{"c1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, "ORDER BY created_at DESC, id DESC") || strings.Contains(sql, "ts_rank") {
+		t.Errorf("query without text = %s", sql)
+	}
+}
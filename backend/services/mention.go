@@ -0,0 +1,228 @@
+// services/mention.go - @mention parsing, resolution and notification
+package services
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxMentionTokens bounds the @names resolved per message
+const maxMentionTokens = 50
+
+// mentionPattern matches @name not preceded by a word character, so email
+// addresses in the text are not mentions. Names may be emails themselves.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w][\w.+\-@]*)`)
+
+// ParseMentions returns the lower-cased names mentioned in text, without
+// duplicates, and whether @channel or @here was used
+func ParseMentions(text string) (names []string, channel, here bool) {
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(strings.TrimRight(m[1], ".-+@"))
+		switch {
+		case name == "channel" || name == "everyone":
+			channel = true
+		case name == "here":
+			here = true
+		case name != "" && !seen[name] && len(names) < maxMentionTokens:
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, channel, here
+}
+
+// ResolveMentions returns the mentions in text sent by authorID to channel,
+// without message IDs. A name resolves to the user of the channel's tenant
+// with that email, or else to the only user whose email local part or name
+// without spaces equals it. Users who cannot read the channel and the author
+// are not mentioned.
+func ResolveMentions(ctx context.Context, channel *models.Channel, authorID, text string) ([]models.Mention, error) {
+	names, everyone, here := ParseMentions(text)
+	if len(names) == 0 && !everyone && !here {
+		return nil, nil
+	}
+	tenantUsers := db.TenantUsers(ctx, channel.TenantID, false)
+
+	kinds := make(map[string]models.MentionKind)
+	var order []string
+	add := func(userID string, kind models.MentionKind) {
+		if userID == authorID {
+			return
+		}
+		if _, ok := kinds[userID]; !ok {
+			order = append(order, userID)
+			kinds[userID] = kind
+		}
+	}
+
+	if len(names) > 0 {
+		conds := make([]string, 0, 2*len(names)+1)
+		args := make([]interface{}, 0, 2*len(names)+1)
+		conds = append(conds, "LOWER(email) IN ?")
+		args = append(args, names)
+		for _, n := range names {
+			conds = append(conds, "LOWER(email) LIKE ?", "LOWER(REPLACE(name, ' ', '')) = ?")
+			args = append(args, n+"@%", n)
+		}
+		var users []models.User
+		if err := tenantUsers.Select("users.id", "users.email", "users.name").Where(strings.Join(conds, " OR "), args...).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if id := resolveMentionName(n, users); id != "" {
+				add(id, models.MentionUser)
+			}
+		}
+	}
+
+	var members []string
+	if everyone || here || channel.Type.MembersOnly() {
+		err := db.DB.WithContext(ctx).Model(&models.ChannelMember{}).
+			Where("channel_id = ?", channel.ID).Pluck("user_id", &members).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if channel.Type.MembersOnly() {
+		isMember := make(map[string]bool, len(members))
+		for _, id := range members {
+			isMember[id] = true
+		}
+		kept := order[:0]
+		for _, id := range order {
+			if isMember[id] {
+				kept = append(kept, id)
+			} else {
+				delete(kinds, id)
+			}
+		}
+		order = kept
+	}
+	if everyone || here {
+		kind := models.MentionChannel
+		if !everyone {
+			kind = models.MentionHere
+		}
+		for _, id := range members {
+			add(id, kind)
+		}
+	}
+
+	mentions := make([]models.Mention, len(order))
+	for i, id := range order {
+		mentions[i] = models.Mention{
+			TenantID:  channel.TenantID,
+			UserID:    id,
+			ChannelID: channel.StreamID,
+			AuthorID:  authorID,
+			Kind:      kinds[id],
+		}
+	}
+	return mentions, nil
+}
+
+// resolveMentionName picks the user name refers to, or "" if there is no
+// unambiguous match
+func resolveMentionName(name string, users []models.User) string {
+	var byLocal, byName []string
+	for _, u := range users {
+		email := strings.ToLower(u.Email)
+		if email == name {
+			return u.ID
+		}
+		if strings.HasPrefix(email, name+"@") {
+			byLocal = append(byLocal, u.ID)
+		}
+		if strings.ToLower(strings.ReplaceAll(u.Name, " ", "")) == name {
+			byName = append(byName, u.ID)
+		}
+	}
+	if len(byLocal) == 1 {
+		return byLocal[0]
+	}
+	if len(byLocal) == 0 && len(byName) == 1 {
+		return byName[0]
+	}
+	return ""
+}
+
+// MentionedUserIDs returns the IDs of the users in mentions
+func MentionedUserIDs(mentions []models.Mention) []string {
+	ids := make([]string, len(mentions))
+	for i, m := range mentions {
+		ids[i] = m.UserID
+	}
+	return ids
+}
+
+// RecordMentions stores the mentions of msg and notifies the mentioned users
+// in the background. Notification failures are logged; they do not fail the message.
+func RecordMentions(ctx context.Context, msg *models.Message, mentions []models.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	for i := range mentions {
+		mentions[i].MessageID = msg.ID
+	}
+	err := db.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error
+	if err != nil {
+		return err
+	}
+	notifications := make([]Notification, len(mentions))
+	for i, m := range mentions {
+		notifications[i] = Notification{
+			Kind:      NotificationMention,
+			TenantID:  m.TenantID,
+			UserID:    m.UserID,
+			ActorID:   m.AuthorID,
+			ChannelID: m.ChannelID,
+			MessageID: m.MessageID,
+			Text:      msg.Text,
+			CreatedAt: msg.CreatedAt,
+		}
+	}
+	notifyAsync(notifications)
+	return nil
+}
+
+// UpdateMentions brings the stored mentions of an edited msg in line with
+// mentions: users no longer mentioned are dropped and only the newly
+// mentioned ones are notified
+func UpdateMentions(ctx context.Context, msg *models.Message, mentions []models.Mention) error {
+	keep := make([]string, len(mentions))
+	for i, m := range mentions {
+		keep[i] = m.UserID
+	}
+	var existing []string
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Mention{}).Where("message_id = ?", msg.ID).Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+		dropped := tx.Where("message_id = ?", msg.ID)
+		if len(keep) > 0 {
+			dropped = dropped.Where("user_id NOT IN ?", keep)
+		}
+		return dropped.Delete(&models.Mention{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	var added []models.Mention
+	for _, m := range mentions {
+		if !known[m.UserID] {
+			added = append(added, m)
+		}
+	}
+	return RecordMentions(ctx, msg, added)
+}
@@ -0,0 +1,66 @@
+// services/message.go - Message editing, deletion and edit history
+package services
+
+import (
+	"context"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm/clause"
+)
+
+// EditMessage changes the text of msg in the chat provider and records the
+// previous text as a revision
+func EditMessage(ctx context.Context, msg *models.Message, editorID, text string) (*models.Message, error) {
+	updated, err := Chat().UpdateMessage(ctx, msg.ID, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordRevision(ctx, msg, editorID, models.RevisionEdit); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteMessage turns msg into a tombstone in the chat provider and records
+// its text as a revision
+func DeleteMessage(ctx context.Context, msg *models.Message, deleterID string) error {
+	if err := Chat().DeleteMessage(ctx, msg.ID); err != nil {
+		return err
+	}
+	return recordRevision(ctx, msg, deleterID, models.RevisionDelete)
+}
+
+func recordRevision(ctx context.Context, msg *models.Message, editorID string, action models.RevisionAction) error {
+	return db.DB.WithContext(ctx).Create(&models.MessageRevision{
+		MessageID: msg.ID,
+		ChannelID: msg.ChannelID,
+		EditorID:  editorID,
+		Action:    action,
+		Text:      msg.Text,
+	}).Error
+}
+
+// MessageHistory returns the revisions of a message, oldest first
+func MessageHistory(ctx context.Context, messageID string) ([]models.MessageRevision, error) {
+	var revisions []models.MessageRevision
+	err := db.DB.WithContext(ctx).Where("message_id = ?", messageID).Order("created_at").Find(&revisions).Error
+	return revisions, err
+}
+
+// AddThreadParticipants records that userIDs take part in the thread of parentID
+func AddThreadParticipants(ctx context.Context, parentID string, userIDs ...string) error {
+	participants := make([]models.ThreadParticipant, len(userIDs))
+	for i, id := range userIDs {
+		participants[i] = models.ThreadParticipant{ParentID: parentID, UserID: id}
+	}
+	return db.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&participants).Error
+}
+
+// ThreadParticipants returns the IDs of the users taking part in the thread of parentID
+func ThreadParticipants(ctx context.Context, parentID string) ([]string, error) {
+	var ids []string
+	err := db.DB.WithContext(ctx).Model(&models.ThreadParticipant{}).
+		Where("parent_id = ?", parentID).Order("created_at").Pluck("user_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,113 @@
+// services/notify.go - Delivery of user notifications
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// NotificationMention is sent to users mentioned in a message
+const NotificationMention = "mention"
+
+// notifyTimeout bounds the delivery of one batch of notifications
+const notifyTimeout = 30 * time.Second
+
+// Notification tells a user about something that happened in their tenant
+type Notification struct {
+	Kind      string    `json:"kind"`
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"` // recipient
+	ActorID   string    `json:"actor_id"`
+	ChannelID string    `json:"channel_id"` // Stream channel ID
+	MessageID string    `json:"message_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+var notifier Notifier
+var notifierOnce sync.Once
+
+// Notifications returns the configured notifier. Notifications are POSTed as
+// JSON to NOTIFICATION_WEBHOOK_URL when it is set and logged otherwise.
+func Notifications() Notifier {
+	notifierOnce.Do(func() {
+		if notifier != nil {
+			return
+		}
+		if url := os.Getenv("NOTIFICATION_WEBHOOK_URL"); url != "" {
+			notifier = &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 5 * time.Second}}
+		} else {
+			notifier = LogNotifier{}
+		}
+	})
+	return notifier
+}
+
+// SetNotifier replaces the configured notifier
+func SetNotifier(n Notifier) {
+	notifierOnce.Do(func() {})
+	notifier = n
+}
+
+// notifyAsync delivers notifications in order in the background, so that a
+// slow webhook does not hold up the request that triggered them. Failures are
+// logged.
+func notifyAsync(notifications []Notification) {
+	n := Notifications()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		for _, notification := range notifications {
+			if err := n.Notify(ctx, notification); err != nil {
+				log.Printf("notify %s of %s in %s: %v", notification.UserID, notification.Kind, notification.MessageID, err)
+			}
+		}
+	}()
+}
+
+// LogNotifier writes notifications to the server log
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("notification %s for user %s: message %s in %s", n.Kind, n.UserID, n.MessageID, n.ChannelID)
+	return nil
+}
+
+// WebhookNotifier POSTs each notification as JSON to URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook: %s", resp.Status)
+	}
+	return nil
+}
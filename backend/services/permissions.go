@@ -0,0 +1,92 @@
+// services/permissions.go - Per-tenant permission resolution
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+// EffectivePermissions returns the permissions of role in tenantID:
+// the defaults with the tenant's overrides applied
+func EffectivePermissions(ctx context.Context, tenantID string, role models.Role) (map[models.Permission]bool, error) {
+	perms := make(map[models.Permission]bool)
+	for _, p := range models.DefaultPermissions[role] {
+		perms[p] = true
+	}
+	var overrides []models.PermissionOverride
+	err := db.ForTenant(ctx, tenantID).Where("role = ?", role).Find(&overrides).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if o.Allowed {
+			perms[o.Permission] = true
+		} else {
+			delete(perms, o.Permission)
+		}
+	}
+	return perms, nil
+}
+
+// HasPermission reports whether role may perform perm in tenantID
+func HasPermission(ctx context.Context, tenantID string, role models.Role, perm models.Permission) (bool, error) {
+	perms, err := EffectivePermissions(ctx, tenantID, role)
+	if err != nil {
+		return false, err
+	}
+	return perms[perm], nil
+}
+
+// PermissionList returns the granted permissions in a stable order
+func PermissionList(perms map[models.Permission]bool) []models.Permission {
+	list := make([]models.Permission, 0, len(perms))
+	for p, ok := range perms {
+		if ok {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// SetRolePermissions makes perms the exact permission set of role in tenantID.
+// Only the differences from the defaults are stored as overrides.
+func SetRolePermissions(ctx context.Context, tenantID string, role models.Role, perms []models.Permission) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return setRolePermissions(tx, tenantID, role, perms)
+	})
+}
+
+func setRolePermissions(tx *gorm.DB, tenantID string, role models.Role, perms []models.Permission) error {
+	want := make(map[models.Permission]bool)
+	for _, p := range perms {
+		want[p] = true
+	}
+	defaults := make(map[models.Permission]bool)
+	for _, p := range models.DefaultPermissions[role] {
+		defaults[p] = true
+	}
+	err := tx.Where("tenant_id = ? AND role = ?", tenantID, role).Delete(&models.PermissionOverride{}).Error
+	if err != nil {
+		return err
+	}
+	for _, p := range models.AllPermissions {
+		if want[p] == defaults[p] {
+			continue
+		}
+		o := models.PermissionOverride{TenantID: tenantID, Role: role, Permission: p, Allowed: want[p]}
+		if err := tx.Create(&o).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetRolePermissions drops the tenant's overrides for role
+func ResetRolePermissions(ctx context.Context, tenantID string, role models.Role) error {
+	return db.ForTenant(ctx, tenantID).Where("role = ?", role).Delete(&models.PermissionOverride{}).Error
+}
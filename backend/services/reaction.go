@@ -0,0 +1,83 @@
+// services/reaction.go - Emoji reactions on messages
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm/clause"
+)
+
+// AddReaction stores userID's emoji reaction to msg and mirrors it to the chat provider.
+// Reacting twice with the same emoji is a no-op.
+func AddReaction(ctx context.Context, msg *models.Message, userID, emoji string) error {
+	reaction := models.Reaction{MessageID: msg.ID, UserID: userID, Emoji: emoji, ChannelID: msg.ChannelID}
+	res := db.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	if err := Chat().AddReaction(ctx, msg.ID, userID, emoji); err != nil {
+		db.DB.WithContext(ctx).Delete(&reaction)
+		return err
+	}
+	return nil
+}
+
+// RemoveReaction deletes userID's emoji reaction to msg, if any
+func RemoveReaction(ctx context.Context, msg *models.Message, userID, emoji string) error {
+	res := db.DB.WithContext(ctx).Where("message_id = ? AND user_id = ? AND emoji = ?", msg.ID, userID, emoji).
+		Delete(&models.Reaction{})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	return Chat().RemoveReaction(ctx, msg.ID, userID, emoji)
+}
+
+// AttachReactions fills the Reactions of messages with per-emoji counts,
+// marking the emojis userID reacted with
+func AttachReactions(ctx context.Context, messages []models.Message, userID string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	var rows []struct {
+		MessageID string
+		Emoji     string
+		Count     int
+		Reacted   int
+	}
+	err := db.DB.WithContext(ctx).Model(&models.Reaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS reacted", userID).
+		Where("message_id IN ?", ids).
+		Group("message_id, emoji").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	byMessage := make(map[string][]models.ReactionSummary)
+	for _, r := range rows {
+		byMessage[r.MessageID] = append(byMessage[r.MessageID], models.ReactionSummary{
+			Emoji: r.Emoji, Count: r.Count, Reacted: r.Reacted > 0,
+		})
+	}
+	for i := range messages {
+		summaries := byMessage[messages[i].ID]
+		// Most used first, then by emoji for a stable order
+		sort.Slice(summaries, func(a, b int) bool {
+			if summaries[a].Count != summaries[b].Count {
+				return summaries[a].Count > summaries[b].Count
+			}
+			return summaries[a].Emoji < summaries[b].Emoji
+		})
+		if summaries == nil {
+			summaries = []models.ReactionSummary{}
+		}
+		messages[i].Reactions = summaries
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+const defaultDeleteGracePeriod = 30 * 24 * time.Hour
+
+// ErrGracePeriodExpired is returned when restoring something deleted
+// longer ago than DeleteGracePeriod
+var ErrGracePeriodExpired = errors.New("grace period expired")
+
+// DeleteGracePeriod is how long deleted users, channels and tenants can be
+// restored; DELETE_GRACE_PERIOD overrides the default of 30 days
+func DeleteGracePeriod() time.Duration {
+	return durationEnv("DELETE_GRACE_PERIOD", defaultDeleteGracePeriod)
+}
+
+// RestorableSince is the oldest deletion time that can still be restored
+func RestorableSince() time.Time {
+	return time.Now().Add(-DeleteGracePeriod())
+}
+
+func checkRestorable(deletedAt time.Time) error {
+	if deletedAt.Before(RestorableSince()) {
+		return ErrGracePeriodExpired
+	}
+	return nil
+}
+
+// RemoveUser removes a user from user.TenantID and revokes their sessions;
+// their messages and channel memberships stay. Removing a user from their
+// last tenant also deletes the account and deactivates the chat-side user.
+func RemoveUser(ctx context.Context, user *models.User) error {
+	var others int64
+	err := db.DB.WithContext(ctx).Model(&models.TenantMember{}).
+		Where("user_id = ? AND tenant_id <> ? AND removed_at IS NULL", user.ID, user.TenantID).
+		Count(&others).Error
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		if err := Chat().DeactivateUser(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	if err := RevokeUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND user_id = ?", user.TenantID, user.ID).
+			Update("removed_at", now).Error
+		if err != nil || others > 0 {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.RemovedAt = &now
+	return nil
+}
+
+// RestoreUser undoes RemoveUser within the grace period
+func RestoreUser(ctx context.Context, user *models.User) error {
+	if user.RemovedAt != nil {
+		if err := checkRestorable(*user.RemovedAt); err != nil {
+			return err
+		}
+	}
+	if user.DeletedAt.Valid {
+		if err := Chat().ReactivateUser(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND user_id = ?", user.TenantID, user.ID).
+			Update("removed_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.RemovedAt = nil
+	user.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+// DeleteTenant soft-deletes a tenant; its users can no longer sign in or
+// use their tokens until it is restored
+func DeleteTenant(ctx context.Context, tenant *models.Tenant) error {
+	return db.DB.WithContext(ctx).Delete(tenant).Error
+}
+
+// RestoreTenant undoes DeleteTenant within the grace period
+func RestoreTenant(ctx context.Context, tenant *models.Tenant) error {
+	if err := checkRestorable(tenant.DeletedAt.Time); err != nil {
+		return err
+	}
+	if err := db.DB.WithContext(ctx).Unscoped().Model(tenant).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	tenant.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
@@ -0,0 +1,114 @@
+// services/roles.go - Built-in and custom per-tenant roles
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Tabintel/multi-tenant-chat/backend/db"
+	"github.com/Tabintel/multi-tenant-chat/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRoleNameTaken is returned when a custom role would shadow a built-in or existing role
+	ErrRoleNameTaken = errors.New("role name already in use")
+	// ErrRoleInUse is returned when deleting a custom role that is still assigned
+	ErrRoleInUse = errors.New("role is assigned to users")
+)
+
+// RoleExists reports whether role is a built-in role or a custom role of tenantID
+func RoleExists(ctx context.Context, tenantID string, role models.Role) (bool, error) {
+	if models.IsBuiltinRole(role) {
+		return true, nil
+	}
+	var count int64
+	err := db.ForTenant(ctx, tenantID).Model(&models.TenantRole{}).Where("name = ?", string(role)).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateTenantRole creates a custom role with the given permissions
+func CreateTenantRole(ctx context.Context, role *models.TenantRole, perms []models.Permission) error {
+	if err := checkRoleName(ctx, role.TenantID, role.Name, ""); err != nil {
+		return err
+	}
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.TenantID, models.Role(role.Name), perms)
+	})
+}
+
+// RenameTenantRole renames a custom role, moving its users and permissions along.
+// It returns the IDs of the users whose tokens must be revoked.
+func RenameTenantRole(ctx context.Context, role *models.TenantRole, newName string) ([]string, error) {
+	if newName == role.Name {
+		return nil, nil
+	}
+	if err := checkRoleName(ctx, role.TenantID, newName, role.ID); err != nil {
+		return nil, err
+	}
+	var userIDs []string
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		members := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND role = ?", role.TenantID, role.Name)
+		if err := members.Pluck("user_id", &userIDs).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND role = ?", role.TenantID, role.Name).
+			Update("role", newName).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.PermissionOverride{}).Where("tenant_id = ? AND role = ?", role.TenantID, role.Name).
+			Update("role", newName).Error
+		if err != nil {
+			return err
+		}
+		role.Name = newName
+		return tx.Model(role).Update("name", newName).Error
+	})
+	return userIDs, err
+}
+
+// DeleteTenantRole deletes an unassigned custom role and its permissions
+func DeleteTenantRole(ctx context.Context, role models.TenantRole) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var assigned int64
+		err := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND role = ?", role.TenantID, role.Name).Count(&assigned).Error
+		if err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return ErrRoleInUse
+		}
+		err = tx.Where("tenant_id = ? AND role = ?", role.TenantID, role.Name).Delete(&models.PermissionOverride{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+// checkRoleName rejects names of built-in roles (case-insensitively) and
+// of other custom roles of the tenant
+func checkRoleName(ctx context.Context, tenantID, name, exceptID string) error {
+	for _, r := range models.BuiltinRoles {
+		if strings.EqualFold(name, string(r)) {
+			return ErrRoleNameTaken
+		}
+	}
+	q := db.ForTenant(ctx, tenantID).Model(&models.TenantRole{}).Where("LOWER(name) = LOWER(?)", name)
+	if exceptID != "" {
+		q = q.Where("id <> ?", exceptID)
+	}
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleNameTaken
+	}
+	return nil
+}
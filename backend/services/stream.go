@@ -4,11 +4,14 @@ import (
 	"os"
 	"time"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	stream "github.com/GetStream/stream-chat-go/v5"
-	"github.com/google/uuid"
 	"github.com/Tabintel/multi-tenant-chat/backend/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -33,44 +36,59 @@ func GetStreamClient() *stream.Client {
 	return streamClient
 }
 
-// CreateStreamToken generates a Stream Chat token for a user
-func CreateStreamToken(userID string) (string, error) {
+// StreamChat is the ChatProvider backed by GetStream
+type StreamChat struct {
+	client *stream.Client
+}
+
+// NewStreamChat wraps a Stream client as a ChatProvider
+func NewStreamChat(client *stream.Client) *StreamChat {
+	return &StreamChat{client: client}
+}
+
+func (s *StreamChat) channel(channelID string) *stream.Channel {
+	return s.client.Channel("messaging", channelID)
+}
+
+// CreateToken generates a Stream Chat token for a user
+func (s *StreamChat) CreateToken(userID string, expiresAt time.Time) (string, error) {
 	if userID == "" {
 		return "", fmt.Errorf("userID is required for token generation")
 	}
-	c := GetStreamClient()
-	token, err := c.CreateToken(userID, time.Now().Add(24*time.Hour))
-	return token, err
-}
-
-func GenerateStreamToken(userID string) (string, error) {
-	return CreateStreamToken(userID)
+	return s.client.CreateToken(userID, expiresAt)
 }
 
-func CreateStreamUser(user models.User) error {
-	client := GetStreamClient()
-	_, err := client.UpsertUser(context.Background(), &stream.User{
+// UpsertUser syncs the user's name and email. Everyone gets Stream's "user"
+// role: tenant roles, custom ones included, are enforced by this API, and
+// Stream's "admin" role is app-wide, so it would reach other tenants'
+// channels. The tenant is not stored either, since a user can belong to
+// several; channels carry theirs.
+func (s *StreamChat) UpsertUser(ctx context.Context, user models.User) error {
+	_, err := s.client.UpsertUser(ctx, &stream.User{
 		ID:   user.ID,
 		Name: user.Name,
-		Role: string(user.Role),
+		Role: "user",
 		ExtraData: map[string]interface{}{
-			"tenant_id": user.TenantID,
-			"email":     user.Email,
+			"email": user.Email,
 		},
 	})
 	return err
 }
 
-func CreateStreamChannel(channel models.Channel, creatorID string) (string, error) {
-	client := GetStreamClient()
-	// Ensure channelID is <= 64 characters for Stream
-	shortTenantID := channel.TenantID
-	if len(shortTenantID) > 8 {
-		shortTenantID = shortTenantID[:8]
-	}
-	channelID := shortTenantID + "-" + uuid.New().String()
-	ch, err := client.CreateChannel(
-		context.Background(),
+func (s *StreamChat) DeactivateUser(ctx context.Context, userID string) error {
+	_, err := s.client.DeactivateUser(ctx, userID)
+	return err
+}
+
+func (s *StreamChat) ReactivateUser(ctx context.Context, userID string) error {
+	_, err := s.client.ReactivateUser(ctx, userID)
+	return err
+}
+
+func (s *StreamChat) CreateChannel(ctx context.Context, channel models.Channel, creatorID string) (string, error) {
+	channelID := newChannelID(channel.TenantID)
+	ch, err := s.client.CreateChannel(
+		ctx,
 		"messaging",
 		channelID,
 		creatorID,
@@ -90,13 +108,12 @@ func CreateStreamChannel(channel models.Channel, creatorID string) (string, erro
 	return ch.Channel.ID, nil // ch is *CreateChannelResponse
 }
 
-func GetTenantChannels(tenantID string) ([]models.Channel, error) {
-	client := GetStreamClient()
+func (s *StreamChat) QueryChannels(ctx context.Context, tenantID string) ([]models.Channel, error) {
 	filter := map[string]interface{}{
 		"tenant_id": tenantID,
 		"type":      "messaging",
 	}
-	resp, err := client.QueryChannels(context.Background(), &stream.QueryOption{
+	resp, err := s.client.QueryChannels(ctx, &stream.QueryOption{
 		Filter: filter,
 		Limit:  100,
 	})
@@ -112,7 +129,7 @@ func GetTenantChannels(tenantID string) ([]models.Channel, error) {
 			createdBy = ch.CreatedBy.ID
 		}
 		result[i] = models.Channel{
-			ID:          ch.ID,
+			StreamID:    ch.ID,
 			Name:        name,
 			Description: description,
 			TenantID:    tenantID,
@@ -122,6 +139,270 @@ func GetTenantChannels(tenantID string) ([]models.Channel, error) {
 	return result, nil
 }
 
+func (s *StreamChat) UpdateChannel(ctx context.Context, channelID string, channel models.Channel) error {
+	_, err := s.channel(channelID).PartialUpdate(ctx, stream.PartialUpdate{
+		Set: map[string]interface{}{
+			"name":        channel.Name,
+			"description": channel.Description,
+			"frozen":      channel.ArchivedAt != nil,
+		},
+	})
+	return err
+}
+
+// DeleteChannel disables the channel rather than deleting it, since Stream
+// cannot undelete channels
+func (s *StreamChat) DeleteChannel(ctx context.Context, channelID string) error {
+	return s.setDisabled(ctx, channelID, true)
+}
+
+func (s *StreamChat) RestoreChannel(ctx context.Context, channelID string) error {
+	return s.setDisabled(ctx, channelID, false)
+}
+
+func (s *StreamChat) setDisabled(ctx context.Context, channelID string, disabled bool) error {
+	_, err := s.channel(channelID).PartialUpdate(ctx, stream.PartialUpdate{
+		Set: map[string]interface{}{"disabled": disabled},
+	})
+	return err
+}
+
+func (s *StreamChat) AddMembers(ctx context.Context, channelID string, userIDs []string) error {
+	_, err := s.channel(channelID).AddMembers(ctx, userIDs)
+	return err
+}
+
+func (s *StreamChat) RemoveMembers(ctx context.Context, channelID string, userIDs []string) error {
+	_, err := s.channel(channelID).RemoveMembers(ctx, userIDs, nil)
+	return err
+}
+
+func (s *StreamChat) ListMembers(ctx context.Context, channelID string) ([]string, error) {
+	resp, err := s.channel(channelID).QueryMembers(ctx, &stream.QueryOption{
+		Filter: map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.Members))
+	for _, m := range resp.Members {
+		ids = append(ids, m.UserID)
+	}
+	return ids, nil
+}
+
+func (s *StreamChat) SendMessage(ctx context.Context, channelID string, in MessageInput) (*models.Message, error) {
+	// Download URLs expire, so Stream only keeps a reference to our attachments
+	attachments := make([]*stream.Attachment, len(in.Attachments))
+	for i, a := range in.Attachments {
+		kind := "file"
+		if strings.HasPrefix(a.ContentType, "image/") {
+			kind = "image"
+		}
+		attachments[i] = &stream.Attachment{
+			Type:      kind,
+			Title:     a.Filename,
+			ExtraData: map[string]interface{}{"attachment_id": a.ID, "mime_type": a.ContentType, "file_size": a.Size},
+		}
+	}
+	resp, err := s.channel(channelID).SendMessage(ctx, &stream.Message{
+		Text:        in.Text,
+		User:        &stream.User{ID: in.UserID},
+		ParentID:    in.ParentID,
+		Attachments:    attachments,
+		MentionedUsers: mentionedUsers(in.MentionedUserIDs),
+	}, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	msg := fromStreamMessage(channelID, resp.Message)
+	return &msg, nil
+}
+
+func (s *StreamChat) GetMessages(ctx context.Context, channelID string, q MessageQuery) (*MessagePage, error) {
+	resp, err := s.channel(channelID).Query(ctx, &stream.QueryRequest{
+		State: true,
+		Messages: &stream.MessagePaginationParamsRequest{
+			PaginationParamsRequest: stream.PaginationParamsRequest{
+				Limit: q.limit() + 1,
+				IDLT:  q.BeforeID,
+				IDGT:  q.AfterID,
+			},
+			CreatedAtBefore: q.Before,
+			CreatedAtAfter:  q.After,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]models.Message, len(resp.Messages))
+	for i, m := range resp.Messages {
+		messages[i] = fromStreamMessage(channelID, m)
+	}
+	return newMessagePage(messages, q), nil
+}
+
+func (s *StreamChat) GetReplies(ctx context.Context, parentID string, q MessageQuery) (*MessagePage, error) {
+	options := map[string][]string{"limit": {strconv.Itoa(q.limit() + 1)}}
+	if q.BeforeID != "" {
+		options["id_lt"] = []string{q.BeforeID}
+	}
+	if q.AfterID != "" {
+		options["id_gt"] = []string{q.AfterID}
+	}
+	if q.Before != nil {
+		options["created_at_before"] = []string{q.Before.Format(time.RFC3339Nano)}
+	}
+	if q.After != nil {
+		options["created_at_after"] = []string{q.After.Format(time.RFC3339Nano)}
+	}
+	parent, err := s.GetMessage(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.channel(parent.ChannelID).GetReplies(ctx, parentID, options)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]models.Message, len(resp.Messages))
+	for i, m := range resp.Messages {
+		messages[i] = fromStreamMessage(parent.ChannelID, m)
+	}
+	return newMessagePage(messages, q), nil
+}
+
+func (s *StreamChat) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	resp, err := s.client.GetMessage(ctx, messageID)
+	if err != nil {
+		var apiErr stream.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	msg := fromStreamMessage(channelIDFromCID(resp.Message.CID), resp.Message)
+	return &msg, nil
+}
+
+func (s *StreamChat) UpdateMessage(ctx context.Context, messageID, text string) (*models.Message, error) {
+	resp, err := s.client.PartialUpdateMessage(ctx, messageID, &stream.MessagePartialUpdateRequest{
+		PartialUpdate: stream.PartialUpdate{
+			Set: map[string]interface{}{"text": text, "edited_at": time.Now().UTC()},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg := fromStreamMessage(channelIDFromCID(resp.Message.CID), resp.Message)
+	return &msg, nil
+}
+
+// DeleteMessage soft-deletes the message; Stream keeps it as type "deleted"
+func (s *StreamChat) DeleteMessage(ctx context.Context, messageID string) error {
+	_, err := s.client.DeleteMessage(ctx, messageID)
+	return err
+}
+
+func (s *StreamChat) AddReaction(ctx context.Context, messageID, userID, emoji string) error {
+	_, err := s.client.SendReaction(ctx, &stream.Reaction{Type: emoji, UserID: userID}, messageID, userID)
+	return err
+}
+
+func (s *StreamChat) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+	_, err := s.client.DeleteReaction(ctx, messageID, emoji, userID)
+	return err
+}
+
+func (s *StreamChat) SearchMessages(ctx context.Context, q SearchQuery) (*SearchPage, error) {
+	cids := make([]string, len(q.ChannelIDs))
+	for i, id := range q.ChannelIDs {
+		cids[i] = "messaging:" + id
+	}
+	// Stream accepts either a query or message filters, so the text is a filter too
+	filters := map[string]interface{}{"deleted_at": map[string]interface{}{"$exists": false}}
+	if q.Text != "" {
+		filters["text"] = map[string]interface{}{"$q": q.Text}
+	}
+	if q.UserID != "" {
+		filters["user.id"] = q.UserID
+	}
+	created := map[string]interface{}{}
+	if q.Before != nil {
+		created["$lt"] = q.Before.Format(time.RFC3339Nano)
+	}
+	if q.After != nil {
+		created["$gt"] = q.After.Format(time.RFC3339Nano)
+	}
+	if len(created) > 0 {
+		filters["created_at"] = created
+	}
+	if q.HasAttachment {
+		filters["attachments"] = map[string]interface{}{"$exists": true}
+	}
+	resp, err := s.client.SearchWithFullResponse(ctx, stream.SearchRequest{
+		Filters:        map[string]interface{}{"cid": map[string]interface{}{"$in": cids}},
+		MessageFilters: filters,
+		Limit:          q.limit(),
+		Next:           q.Cursor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &SearchPage{Messages: make([]models.Message, 0, len(resp.Results)), Next: resp.Next}
+	for _, r := range resp.Results {
+		if r.Message != nil {
+			page.Messages = append(page.Messages, fromStreamMessage(channelIDFromCID(r.Message.CID), r.Message))
+		}
+	}
+	return page, nil
+}
+
+func mentionedUsers(ids []string) []*stream.User {
+	users := make([]*stream.User, len(ids))
+	for i, id := range ids {
+		users[i] = &stream.User{ID: id}
+	}
+	return users
+}
+
+// channelIDFromCID strips the channel type from a Stream cid ("messaging:<id>")
+func channelIDFromCID(cid string) string {
+	if i := strings.IndexByte(cid, ':'); i >= 0 {
+		return cid[i+1:]
+	}
+	return cid
+}
+
+// fromStreamMessage converts a Stream message to the provider-neutral model
+func fromStreamMessage(channelID string, m *stream.Message) models.Message {
+	msg := models.Message{
+		ID:         m.ID,
+		ChannelID:  channelID,
+		Text:       m.Text,
+		ParentID:   m.ParentID,
+		ReplyCount: m.ReplyCount,
+	}
+	if m.User != nil {
+		msg.UserID = m.User.ID
+	}
+	if m.CreatedAt != nil {
+		msg.CreatedAt = *m.CreatedAt
+	}
+	if m.UpdatedAt != nil {
+		msg.UpdatedAt = *m.UpdatedAt
+	}
+	if m.DeletedAt != nil {
+		msg.DeletedAt = m.DeletedAt
+		msg.Text = ""
+	}
+	if v, ok := m.ExtraData["edited_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			msg.EditedAt = &t
+		}
+	}
+	return msg
+}
+
 // HashPassword hashes a plaintext password using bcrypt
 func HashPassword(password string) ([]byte, error) {
 	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
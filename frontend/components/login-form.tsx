@@ -11,7 +11,6 @@ import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@
 import { toast } from "@/components/ui/use-toast"
 import { Loader2 } from "lucide-react"
 import { useRouter } from "next/navigation"
-import { listTenants } from "@/app/lib/api"
 
 export function LoginForm() {
   const { login } = useAuth()
@@ -23,44 +22,19 @@ export function LoginForm() {
   const [error, setError] = useState("")
   const router = useRouter()
 
-  // Show mock tenants (for demo login only) and merge real backend tenants for real login
+  // Show mock tenants (for demo login only) next to real login. The backend
+  // no longer lists tenants to anonymous callers and signs users in to the
+  // first organization they joined.
   const mockTenants = [
     { id: "tenant-a", name: "Tenant A Corp" },
     { id: "tenant-b", name: "Tenant B Inc" },
     { id: "tenant-c", name: "Tenant C LLC" }
   ];
+  const accountTenant = { id: "account", name: "My organization" };
 
   useEffect(() => {
-    const fetchTenants = async () => {
-      try {
-        // Always fetch without JWT for public endpoint
-        const res = await fetch('http://localhost:8080/tenants');
-        if (!res.ok) throw new Error('Failed to fetch tenants');
-        const data = await res.json();
-        console.log("Fetched tenants from backend:", data); // Log backend tenants for debug
-        const backendTenants = Array.isArray(data) ? data : data.tenants || [];
-        // Patch: support both backend {ID, Name} and frontend {id, name}
-        const normalizedTenants = backendTenants.map((t: any) => ({
-          id: t.id || t.ID,
-          name: t.name || t.Name
-        }));
-        // Merge mock and backend tenants by id
-        const allTenants = [...mockTenants];
-        normalizedTenants.forEach((t: any) => {
-          if (t && t.id && !allTenants.some(mt => mt.id === t.id)) {
-            allTenants.push(t);
-          }
-        });
-        setTenants(allTenants);
-        // Auto-select the most recently created backend tenant if present, else default to first
-        if (normalizedTenants.length > 0) setOrganization(normalizedTenants[normalizedTenants.length - 1].id);
-        else setOrganization(allTenants[0]?.id || "");
-      } catch (e) {
-        setTenants([...mockTenants]);
-        setOrganization(mockTenants[0].id);
-      }
-    };
-    fetchTenants();
+    setTenants([accountTenant, ...mockTenants]);
+    setOrganization(accountTenant.id);
   }, []);
 
   // Enhanced login handler: Only real tenants use backend auth, mock tenants use demo login